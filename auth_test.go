@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// mockTokenSource always hands back the same fixed token, so tests can
+// assert on the Authorization header oauth2.NewClient attaches without
+// going through the real installed-app flow.
+type mockTokenSource struct {
+	token string
+}
+
+func (m mockTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: m.token, TokenType: "Bearer"}, nil
+}
+
+func TestOAuth2ClientAttachesAuthorizationHeader(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	httpClient := oauth2.NewClient(context.Background(), mockTokenSource{token: "test-access-token"})
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if want := "Bearer test-access-token"; gotAuth != want {
+		t.Errorf("expected Authorization header %q, got %q", want, gotAuth)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import "fmt"
+
+// PhotoBackend is the upload/album target ProcessFolder drives. It's the
+// seam that lets the same folder-walking, filtering, dedup and worker-pool
+// pipeline push media into Google Photos, a plain local directory tree, or
+// an S3 bucket without any of that surrounding logic caring which one.
+type PhotoBackend interface {
+	// GetOrCreateAlbum returns the backend-specific album id for title,
+	// reusing existingAlbums as a cache when the backend has one.
+	GetOrCreateAlbum(title string, existingAlbums map[string]string) (string, error)
+	// CreateAlbum always creates a new album, used when a cached album id
+	// turns out to be stale.
+	CreateAlbum(title string) (string, error)
+	// UploadFile uploads the file at fpath (optionally under
+	// filenameOverride) and returns an opaque token that AddToAlbum later
+	// turns into a permanent album entry.
+	UploadFile(fpath string, filenameOverride string) (string, error)
+	// AddToAlbum commits previously uploaded tokens to albumID, returning
+	// the indices (into uploadTokens) that succeeded.
+	AddToAlbum(uploadTokens []string, albumID string) (map[int]bool, error)
+	// ListAlbumItems lists what's already in albumID, keyed by filename, so
+	// ProcessFolder can detect and prompt about duplicates.
+	ListAlbumItems(albumID string) (map[string]RemoteItemInfo, error)
+	// RemoveFromAlbum removes the given item ids from albumID.
+	RemoveFromAlbum(albumID string, mediaItemIDs []string) error
+}
+
+var (
+	_ PhotoBackend = (*GooglePhotosClient)(nil)
+	_ PhotoBackend = (*LocalMirrorBackend)(nil)
+	_ PhotoBackend = (*S3Backend)(nil)
+)
+
+// ThumbnailHasher is an optional capability a PhotoBackend can implement
+// when it can produce a perceptual hash for a remote item without
+// downloading the full file - Google Photos can via its baseUrl thumbnail
+// convention. ProcessFolder type-asserts for this rather than requiring it
+// on every backend, since localfs/s3 have no equivalent remote thumbnail.
+type ThumbnailHasher interface {
+	FetchThumbnailHash(baseURL string) (uint64, error)
+}
+
+var _ ThumbnailHasher = (*GooglePhotosClient)(nil)
+
+// DescriptionSetter is an optional PhotoBackend capability for backends that
+// can attach a free-text caption to media items as they're added to an
+// album. Only Google Photos' batchCreate endpoint has a description field;
+// localfs/s3 have no remote equivalent, so ProcessFolder type-asserts for
+// this the same way it does for ThumbnailHasher rather than requiring it on
+// every backend.
+type DescriptionSetter interface {
+	// AddToAlbumWithDescriptions is AddToAlbum plus a parallel descriptions
+	// slice (same length and order as uploadTokens; empty strings are fine
+	// and simply omit the field for that item).
+	AddToAlbumWithDescriptions(uploadTokens []string, albumID string, descriptions []string) (map[int]bool, error)
+}
+
+var _ DescriptionSetter = (*GooglePhotosClient)(nil)
+
+// MediaItemIDs is an optional PhotoBackend capability for backends whose
+// AddToAlbum call allocates a permanent remote id distinct from the upload
+// token - only Google Photos does, returning mediaItem.id in the
+// batchCreate response. uploadToAlbum uses it, when available, to record
+// that id in the upload store instead of leaving it blank; localfs/s3 just
+// use the upload token itself as their item id, so they have no equivalent.
+type MediaItemIDs interface {
+	// MediaItemID returns the remote mediaItemID an earlier
+	// AddToAlbum/AddToAlbumWithDescriptions call allocated for uploadToken,
+	// and whether one was found at all.
+	MediaItemID(uploadToken string) (string, bool)
+}
+
+var _ MediaItemIDs = (*GooglePhotosClient)(nil)
+
+// BackendConfig bundles the settings any registered backend factory might
+// need. Fields irrelevant to a given backend are simply left zero.
+type BackendConfig struct {
+	// gphotos
+	GooglePhotosClient *GooglePhotosClient
+
+	// localfs
+	Dest string
+
+	// s3
+	S3Bucket    string
+	S3Region    string
+	S3Prefix    string
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+type backendFactory func(cfg BackendConfig) (PhotoBackend, error)
+
+var backendRegistry = map[string]backendFactory{}
+
+// RegisterBackend makes a backend available under name for NewBackend to
+// construct. Called from init() in each backend's own file.
+func RegisterBackend(name string, factory backendFactory) {
+	backendRegistry[name] = factory
+}
+
+// NewBackend constructs the backend registered under name.
+func NewBackend(name string, cfg BackendConfig) (PhotoBackend, error) {
+	factory, ok := backendRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	return factory(cfg)
+}
+
+func init() {
+	RegisterBackend("gphotos", func(cfg BackendConfig) (PhotoBackend, error) {
+		if cfg.GooglePhotosClient == nil {
+			return nil, fmt.Errorf("gphotos backend requires an authenticated client")
+		}
+		return cfg.GooglePhotosClient, nil
+	})
+	RegisterBackend("localfs", func(cfg BackendConfig) (PhotoBackend, error) {
+		if cfg.Dest == "" {
+			return nil, fmt.Errorf("localfs backend requires --dest")
+		}
+		return NewLocalMirrorBackend(cfg.Dest)
+	})
+	RegisterBackend("s3", func(cfg BackendConfig) (PhotoBackend, error) {
+		if cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("s3 backend requires --s3-bucket")
+		}
+		if cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("s3 backend requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return NewS3Backend(cfg), nil
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestNewBackendUnknown(t *testing.T) {
+	if _, err := NewBackend("nope", BackendConfig{}); err == nil {
+		t.Error("expected error for unknown backend name")
+	}
+}
+
+func TestNewBackendGphotosRequiresClient(t *testing.T) {
+	if _, err := NewBackend("gphotos", BackendConfig{}); err == nil {
+		t.Error("expected error when no GooglePhotosClient is provided")
+	}
+	client := &GooglePhotosClient{}
+	backend, err := NewBackend("gphotos", BackendConfig{GooglePhotosClient: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend != PhotoBackend(client) {
+		t.Error("expected the gphotos backend to be the client itself")
+	}
+}
+
+func TestNewBackendLocalfsRequiresDest(t *testing.T) {
+	if _, err := NewBackend("localfs", BackendConfig{}); err == nil {
+		t.Error("expected error when --dest is missing")
+	}
+	dir := t.TempDir() + "/mirror"
+	if _, err := NewBackend("localfs", BackendConfig{Dest: dir}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewBackendS3RequiresBucketAndCreds(t *testing.T) {
+	if _, err := NewBackend("s3", BackendConfig{}); err == nil {
+		t.Error("expected error when --s3-bucket is missing")
+	}
+	if _, err := NewBackend("s3", BackendConfig{S3Bucket: "photos"}); err == nil {
+		t.Error("expected error when AWS credentials are missing")
+	}
+	backend, err := NewBackend("s3", BackendConfig{S3Bucket: "photos", S3AccessKey: "AKIA", S3SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend == nil {
+		t.Error("expected a non-nil S3 backend")
+	}
+}
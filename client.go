@@ -2,25 +2,150 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
 type GooglePhotosClient struct {
 	httpClient *http.Client
 	baseURL    string
+	pacer      *Pacer
+
+	// Resumable upload config. Zero values disable the resumable path and
+	// fall back to a single raw POST for every file. resumableMu guards
+	// resumable since UploadFile/UploadFiles run concurrently across a
+	// worker pool and large files over the resumable threshold can easily
+	// collide on it mid-run.
+	resumableThreshold int64
+	chunkSize          int64
+	resumableMu        sync.Mutex
+	resumable          map[string]ResumableState
+
+	// lastMediaItemIDs records the mediaItem.id Google allocated for each
+	// upload token any addToAlbum call on this client has succeeded on (see
+	// MediaItemIDs), so uploadToAlbum can look the real id up afterwards
+	// instead of storing the upload token as a stand-in.
+	lastMediaItemIDs map[string]string
+
+	progress ProgressSink
 }
 
-func NewGooglePhotosClient(httpClient *http.Client) *GooglePhotosClient {
+// NewGooglePhotosClient runs (or resumes) OAuth2 authentication via
+// Authenticate and wraps the resulting auto-refreshing *http.Client, so
+// callers don't have to thread credsPath/tokenPath through Authenticate
+// themselves. ctx is accepted for symmetry with the rest of main's
+// context-scoped setup; Authenticate itself isn't cancellable mid-flow.
+func NewGooglePhotosClient(ctx context.Context, credsPath, tokenPath string) (*GooglePhotosClient, error) {
+	httpClient, err := Authenticate(credsPath, tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewGooglePhotosClientFromHTTPClient(httpClient), nil
+}
+
+// NewGooglePhotosClientFromHTTPClient builds a client around an
+// already-authenticated http.Client, bypassing Authenticate entirely. Tests
+// use this to inject a client pointed at an httptest.Server.
+func NewGooglePhotosClientFromHTTPClient(httpClient *http.Client) *GooglePhotosClient {
 	return &GooglePhotosClient{
 		httpClient: httpClient,
 		baseURL:    APIBase,
+		pacer:      NewPacer(httpClient),
+		progress:   &ProgressReporter{},
+	}
+}
+
+// WithResumableUploads enables the chunked resumable upload protocol for
+// files at or above threshold bytes, using the given chunk size. states is
+// the resumable session map loaded from the upload store (see
+// UploadStore.Resumable); the client mutates it in place as sessions
+// progress so the caller can persist it back with UploadStore.SyncResumable.
+func (c *GooglePhotosClient) WithResumableUploads(threshold, chunkSize int64, states map[string]ResumableState) *GooglePhotosClient {
+	c.resumableThreshold = threshold
+	c.chunkSize = chunkSize
+	if states == nil {
+		states = make(map[string]ResumableState)
+	}
+	c.resumable = states
+	return c
+}
+
+// WithRetryPolicy overrides the pacer's retry/backoff parameters for every
+// request this client makes (ListAlbums, CreateAlbum, UploadFile,
+// AddToAlbum, ListAlbumItems, RemoveFromAlbum all already route through the
+// shared pacer). maxAttempts is the total number of tries per call
+// including the first, base is the starting backoff interval, and cap is
+// the ceiling it won't grow past.
+func (c *GooglePhotosClient) WithRetryPolicy(maxAttempts int, base, cap time.Duration) *GooglePhotosClient {
+	if c.pacer == nil {
+		c.pacer = NewPacer(c.httpClient)
+	}
+	c.pacer.WithPolicy(maxAttempts, base, cap)
+	return c
+}
+
+// WithProgress attaches a ProgressSink whose byte bar is ticked as files
+// stream to the upload endpoint and whose file bar advances as items land
+// in an album.
+func (c *GooglePhotosClient) WithProgress(p ProgressSink) *GooglePhotosClient {
+	c.progress = p
+	return c
+}
+
+// sink returns c.progress, defaulting to a disabled no-op reporter so a
+// client built directly as a struct literal (as the tests do) doesn't need
+// to set one just to avoid a nil interface call.
+func (c *GooglePhotosClient) sink() ProgressSink {
+	if c.progress == nil {
+		return &ProgressReporter{}
+	}
+	return c.progress
+}
+
+// getJSON issues a paced GET and decodes the JSON response body into out.
+func (c *GooglePhotosClient) getJSON(url string, out interface{}) error {
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed: %d %s", resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// postJSON issues a paced POST with a JSON body and returns the raw response body.
+func (c *GooglePhotosClient) postJSON(url string, body interface{}) ([]byte, int, error) {
+	data, _ := json.Marshal(body)
+
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	return respBody, resp.StatusCode, nil
 }
 
 // ListAlbums returns a map of album title -> album ID
@@ -34,17 +159,6 @@ func (c *GooglePhotosClient) ListAlbums() (map[string]string, error) {
 			url += "&pageToken=" + pageToken
 		}
 
-		resp, err := c.httpClient.Get(url)
-		if err != nil {
-			return nil, fmt.Errorf("list albums request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("list albums failed: %d %s", resp.StatusCode, string(body))
-		}
-
 		var result struct {
 			Albums []struct {
 				ID    string `json:"id"`
@@ -53,8 +167,8 @@ func (c *GooglePhotosClient) ListAlbums() (map[string]string, error) {
 			NextPageToken string `json:"nextPageToken"`
 		}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode albums: %w", err)
+		if err := c.getJSON(url, &result); err != nil {
+			return nil, fmt.Errorf("list albums failed: %w", err)
 		}
 
 		for _, a := range result.Albums {
@@ -75,23 +189,19 @@ func (c *GooglePhotosClient) CreateAlbum(title string) (string, error) {
 	body := map[string]interface{}{
 		"album": map[string]string{"title": title},
 	}
-	data, _ := json.Marshal(body)
 
-	resp, err := c.httpClient.Post(c.baseURL+"/albums", "application/json", bytes.NewReader(data))
+	respBody, status, err := c.postJSON(c.baseURL+"/albums", body)
 	if err != nil {
 		return "", fmt.Errorf("create album request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("create album failed: %d %s", resp.StatusCode, string(respBody))
+	if status != http.StatusOK {
+		return "", fmt.Errorf("create album failed: %d %s", status, string(respBody))
 	}
 
 	var result struct {
 		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(respBody, &result); err != nil {
 		return "", fmt.Errorf("failed to decode album response: %w", err)
 	}
 
@@ -111,46 +221,340 @@ func (c *GooglePhotosClient) GetOrCreateAlbum(title string, existingAlbums map[s
 	return id, nil
 }
 
-// UploadFile uploads a file and returns the upload token
+// UploadFile uploads a file and returns the upload token. Files at or above
+// the configured resumable threshold (see WithResumableUploads) are sent in
+// chunks so a network blip only costs the current chunk, not the whole file.
 func (c *GooglePhotosClient) UploadFile(fpath string, filenameOverride string) (string, error) {
+	filename := filenameOverride
+	if filename == "" {
+		filename = filepath.Base(fpath)
+	}
+
+	if c.resumableThreshold > 0 {
+		info, err := os.Stat(fpath)
+		if err == nil && info.Size() >= c.resumableThreshold {
+			return c.uploadFileResumable(fpath, filename, info, c.chunkSize)
+		}
+	}
+
+	if info, err := os.Stat(fpath); err == nil {
+		c.sink().FileStarted(filename, info.Size())
+	}
+
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		f, err := os.Open(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		req, err := http.NewRequest("POST", c.baseURL+"/uploads", c.sink().WrapReader(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create upload request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-Goog-Upload-File-Name", filename)
+		req.Header.Set("X-Goog-Upload-Protocol", "raw")
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("  ⚠️  Ошибка загрузки %s: %d %s", filename, resp.StatusCode, string(body))
+	}
+
+	return string(body), nil
+}
+
+// UploadFileResumable drives the resumable upload protocol for fpath
+// directly, regardless of the client's configured resumable threshold,
+// using chunkSize for this call only (falling back to the client's own
+// configured chunk size when chunkSize <= 0). UploadFile already switches
+// to this path automatically above the threshold - this is for callers
+// that want resumable semantics unconditionally, e.g. a known-flaky link
+// or a one-off chunk size for a single large file.
+func (c *GooglePhotosClient) UploadFileResumable(fpath, filename string, chunkSize int64) (string, error) {
+	if filename == "" {
+		filename = filepath.Base(fpath)
+	}
+	if chunkSize <= 0 {
+		chunkSize = c.chunkSize
+	}
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return "", fmt.Errorf("stat file: %w", err)
+	}
+	return c.uploadFileResumable(fpath, filename, info, chunkSize)
+}
+
+// uploadFileResumable drives the Google Photos chunked upload protocol:
+// start a session (or resume one persisted from a prior run), then PUT
+// fixed-size chunks until the last one is sent with "upload, finalize".
+func (c *GooglePhotosClient) uploadFileResumable(fpath, filename string, info os.FileInfo, chunkSize int64) (string, error) {
 	f, err := os.Open(fpath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 	defer f.Close()
 
-	filename := filenameOverride
-	if filename == "" {
-		filename = filepath.Base(fpath)
+	c.sink().FileStarted(filename, info.Size())
+
+	absPath, err := filepath.Abs(fpath)
+	if err != nil {
+		absPath = fpath
+	}
+	key := ResumableKey(absPath, info.Size(), info.ModTime())
+
+	uploadURL := ""
+	offset := int64(0)
+	c.resumableMu.Lock()
+	if c.resumable == nil {
+		c.resumable = make(map[string]ResumableState)
+	}
+	if state, ok := c.resumable[key]; ok && time.Now().Before(state.ExpiresAt) {
+		uploadURL = state.UploadURL
+	}
+	c.resumableMu.Unlock()
+	if uploadURL != "" {
+		if resumed, err := c.queryResumableOffset(uploadURL); err == nil {
+			offset = resumed
+		}
+	}
+
+	if uploadURL == "" {
+		uploadURL, err = c.startResumableSession(filename, info.Size())
+		if err != nil {
+			return "", fmt.Errorf("failed to start resumable upload: %w", err)
+		}
+		offset = 0
+	}
+
+	c.resumableMu.Lock()
+	c.resumable[key] = ResumableState{
+		UploadURL: uploadURL,
+		Offset:    offset,
+		ExpiresAt: time.Now().Add(ResumableURLTTL),
+	}
+	c.resumableMu.Unlock()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to seek to resume offset: %w", err)
+	}
+
+	chunk := make([]byte, chunkSize)
+	for offset < info.Size() {
+		n, readErr := io.ReadFull(f, chunk)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			return "", fmt.Errorf("failed to read chunk: %w", readErr)
+		}
+
+		isLast := offset+int64(n) >= info.Size()
+		token, err := c.uploadChunk(uploadURL, chunk[:n], offset, isLast)
+		if err != nil {
+			// Leave the session recorded so the next run can query the
+			// server's real offset and resume instead of restarting.
+			return "", fmt.Errorf("chunk upload failed at offset %d: %w", offset, err)
+		}
+		offset += int64(n)
+		c.resumableMu.Lock()
+		c.resumable[key] = ResumableState{UploadURL: uploadURL, Offset: offset, ExpiresAt: time.Now().Add(ResumableURLTTL)}
+		c.resumableMu.Unlock()
+
+		if isLast {
+			c.resumableMu.Lock()
+			delete(c.resumable, key)
+			c.resumableMu.Unlock()
+			return token, nil
+		}
+	}
+
+	return "", fmt.Errorf("resumable upload finished without a finalize response")
+}
+
+// UploadOptions configures UploadFiles' fan-out.
+type UploadOptions struct {
+	// Parallel is how many files upload concurrently. <= 0 defaults to
+	// DefaultParallelUploads, matching ProcessFolder's own --parallel default.
+	Parallel int
+}
+
+// ProgressEvent is one unit of progress UploadFiles reports on the optional
+// events channel, for callers driving their own CLI progress bar instead of
+// a ProgressSink. Callers that don't need a push-style feed can pass a nil
+// channel.
+type ProgressEvent struct {
+	File string
+	Err  error // set when this file's upload failed
+}
+
+// UploadFilesResult is one path's outcome from UploadFiles, indexed the same
+// as the input paths slice regardless of which worker finished it.
+type UploadFilesResult struct {
+	Path  string
+	Token string
+	Err   error
+}
+
+// UploadFiles fans paths out across opts.Parallel worker goroutines, each
+// calling UploadFile directly - the same pacing, resumable threshold and
+// progress sink as a single-file upload, just concurrent. It's a lower-level
+// primitive than ProcessFolder's own worker pool: no album membership,
+// duplicate prompting or EXIF enrichment, just "upload these paths
+// concurrently and tell me what happened to each one." Every path gets a
+// result (possibly holding an error) rather than UploadFiles itself failing
+// partway through.
+func (c *GooglePhotosClient) UploadFiles(paths []string, opts UploadOptions, events chan<- ProgressEvent) []UploadFilesResult {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	workers := opts.Parallel
+	if workers < 1 {
+		workers = DefaultParallelUploads
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	type job struct {
+		slot int
+		path string
+	}
+	jobCh := make(chan job)
+	results := make([]UploadFilesResult, len(paths))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				token, err := c.UploadFile(j.path, "")
+				// Each worker only ever writes its own job's slot, so this
+				// needs no lock despite results being shared.
+				results[j.slot] = UploadFilesResult{Path: j.path, Token: token, Err: err}
+				if events != nil {
+					events <- ProgressEvent{File: j.path, Err: err}
+				}
+			}
+		}()
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+"/uploads", f)
+	go func() {
+		defer close(jobCh)
+		for i, p := range paths {
+			jobCh <- job{slot: i, path: p}
+		}
+	}()
+	wg.Wait()
+
+	return results
+}
+
+func (c *GooglePhotosClient) startResumableSession(filename string, size int64) (string, error) {
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.baseURL+"/uploads", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Goog-Upload-Command", "start")
+		req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+		req.Header.Set("X-Goog-Upload-Raw-Size", fmt.Sprintf("%d", size))
+		req.Header.Set("X-Goog-Upload-Content-Type", "application/octet-stream")
+		req.Header.Set("X-Goog-Upload-File-Name", filename)
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create upload request: %w", err)
+		return "", err
 	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
 
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("X-Goog-Upload-File-Name", filename)
-	req.Header.Set("X-Goog-Upload-Protocol", "raw")
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	if uploadURL == "" {
+		return "", fmt.Errorf("server did not return an upload URL")
+	}
+	return uploadURL, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+func (c *GooglePhotosClient) queryResumableOffset(uploadURL string) (int64, error) {
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", uploadURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Goog-Upload-Command", "query")
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("upload request failed: %w", err)
+		return 0, err
 	}
 	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
 
-	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("query failed: %d", resp.StatusCode)
+	}
+
+	received := resp.Header.Get("X-Goog-Upload-Size-Received")
+	if received == "" {
+		return 0, fmt.Errorf("no offset in query response")
+	}
+	var offset int64
+	if _, err := fmt.Sscanf(received, "%d", &offset); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
 
+func (c *GooglePhotosClient) uploadChunk(uploadURL string, chunk []byte, offset int64, isLast bool) (string, error) {
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", uploadURL, c.sink().WrapReader(bytes.NewReader(chunk)))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Goog-Upload-Offset", fmt.Sprintf("%d", offset))
+		if isLast {
+			req.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+		} else {
+			req.Header.Set("X-Goog-Upload-Command", "upload")
+		}
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("  ⚠️  Ошибка загрузки %s: %d %s", filename, resp.StatusCode, string(body))
+		return "", fmt.Errorf("%d %s", resp.StatusCode, string(body))
 	}
 
+	if !isLast {
+		return "", nil
+	}
 	return string(body), nil
 }
 
 // AddToAlbum adds uploaded items to an album in batches of 50.
 // Returns the set of successfully added indices (0-based).
 func (c *GooglePhotosClient) AddToAlbum(uploadTokens []string, albumID string) (map[int]bool, error) {
+	return c.addToAlbum(uploadTokens, albumID, nil)
+}
+
+// AddToAlbumWithDescriptions implements DescriptionSetter: same as
+// AddToAlbum, but attaches descriptions[i] as the caption for
+// uploadTokens[i] when it's non-empty.
+func (c *GooglePhotosClient) AddToAlbumWithDescriptions(uploadTokens []string, albumID string, descriptions []string) (map[int]bool, error) {
+	return c.addToAlbum(uploadTokens, albumID, descriptions)
+}
+
+func (c *GooglePhotosClient) addToAlbum(uploadTokens []string, albumID string, descriptions []string) (map[int]bool, error) {
 	successIndices := make(map[int]bool)
 	batchSize := 50
 
@@ -163,43 +567,42 @@ func (c *GooglePhotosClient) AddToAlbum(uploadTokens []string, albumID string) (
 
 		items := make([]map[string]interface{}, len(batch))
 		for i, token := range batch {
-			items[i] = map[string]interface{}{
+			item := map[string]interface{}{
 				"simpleMediaItem": map[string]string{"uploadToken": token},
 			}
+			if descriptions != nil && descriptions[batchStart+i] != "" {
+				item["description"] = descriptions[batchStart+i]
+			}
+			items[i] = item
 		}
 
 		body := map[string]interface{}{
 			"albumId":       albumID,
 			"newMediaItems": items,
 		}
-		data, _ := json.Marshal(body)
 
-		resp, err := c.httpClient.Post(
-			c.baseURL+"/mediaItems:batchCreate",
-			"application/json",
-			bytes.NewReader(data),
-		)
+		respBody, status, err := c.postJSON(c.baseURL+"/mediaItems:batchCreate", body)
 		if err != nil {
 			fmt.Printf("  ⚠️  Ошибка batchCreate: %v\n", err)
 			continue
 		}
 
+		if status != http.StatusOK {
+			return successIndices, fmt.Errorf("batchCreate failed: %d %s", status, string(respBody))
+		}
+
 		var result struct {
 			NewMediaItemResults []struct {
 				Status struct {
 					Code    int    `json:"code"`
 					Message string `json:"message"`
 				} `json:"status"`
+				MediaItem struct {
+					ID string `json:"id"`
+				} `json:"mediaItem"`
 			} `json:"newMediaItemResults"`
 		}
 
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return successIndices, fmt.Errorf("batchCreate failed: %d %s", resp.StatusCode, string(respBody))
-		}
-
 		if err := json.Unmarshal(respBody, &result); err != nil {
 			fmt.Printf("  ⚠️  Ошибка декодирования batchCreate: %v\n", err)
 			continue
@@ -208,20 +611,28 @@ func (c *GooglePhotosClient) AddToAlbum(uploadTokens []string, albumID string) (
 		for i, item := range result.NewMediaItemResults {
 			if item.Status.Message == "Success" || item.Status.Message == "OK" || item.Status.Code == 0 {
 				successIndices[batchStart+i] = true
+				if item.MediaItem.ID != "" {
+					if c.lastMediaItemIDs == nil {
+						c.lastMediaItemIDs = make(map[string]string)
+					}
+					c.lastMediaItemIDs[batch[i]] = item.MediaItem.ID
+				}
+				c.sink().FileDone()
 			} else {
 				fmt.Printf("  ⚠️  Элемент не добавлен: %v\n", item.Status)
 			}
 		}
-
-		// Sleep between batches (not after the last one)
-		if batchEnd < len(uploadTokens) {
-			time.Sleep(1 * time.Second)
-		}
 	}
 
 	return successIndices, nil
 }
 
+// MediaItemID implements MediaItemIDs.
+func (c *GooglePhotosClient) MediaItemID(uploadToken string) (string, bool) {
+	id, ok := c.lastMediaItemIDs[uploadToken]
+	return id, ok
+}
+
 // ListAlbumItems returns remote items in an album keyed by filename
 func (c *GooglePhotosClient) ListAlbumItems(albumID string) (map[string]RemoteItemInfo, error) {
 	items := make(map[string]RemoteItemInfo)
@@ -235,21 +646,21 @@ func (c *GooglePhotosClient) ListAlbumItems(albumID string) (map[string]RemoteIt
 		if pageToken != "" {
 			body["pageToken"] = pageToken
 		}
-		data, _ := json.Marshal(body)
 
-		resp, err := c.httpClient.Post(
-			c.baseURL+"/mediaItems:search",
-			"application/json",
-			bytes.NewReader(data),
-		)
+		respBody, status, err := c.postJSON(c.baseURL+"/mediaItems:search", body)
 		if err != nil {
 			return nil, fmt.Errorf("search request failed: %w", err)
 		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("search failed: %d %s", status, string(respBody))
+		}
 
 		var result struct {
 			MediaItems []struct {
 				ID            string `json:"id"`
 				Filename      string `json:"filename"`
+				BaseURL       string `json:"baseUrl"`
+				MimeType      string `json:"mimeType"`
 				MediaMetadata struct {
 					CreationTime string `json:"creationTime"`
 					Width        string `json:"width"`
@@ -259,11 +670,81 @@ func (c *GooglePhotosClient) ListAlbumItems(albumID string) (map[string]RemoteIt
 			NextPageToken string `json:"nextPageToken"`
 		}
 
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode search response: %w", err)
+		}
+
+		for _, item := range result.MediaItems {
+			items[item.Filename] = RemoteItemInfo{
+				ID:           item.ID,
+				CreationTime: item.MediaMetadata.CreationTime,
+				Width:        item.MediaMetadata.Width,
+				Height:       item.MediaMetadata.Height,
+				BaseURL:      item.BaseURL,
+				MimeType:     item.MimeType,
+			}
+		}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("search failed: %d %s", resp.StatusCode, string(respBody))
+		if result.NextPageToken == "" {
+			break
+		}
+		pageToken = result.NextPageToken
+	}
+
+	return items, nil
+}
+
+// RemoteMediaItem is a single entry returned by ListAlbumItemsOrdered,
+// carrying enough of the Google Photos mediaItem to re-download the
+// original bytes and rebuild a manifest entry for it.
+type RemoteMediaItem struct {
+	ID           string
+	Filename     string
+	BaseURL      string
+	MimeType     string
+	CreationTime string
+	Width        string
+	Height       string
+}
+
+// ListAlbumItemsOrdered returns an album's items in the order the API
+// reports them, unlike ListAlbumItems which collapses them into a map keyed
+// by filename. --download needs the original ordering so a round-trip
+// (download, then re-upload elsewhere) rebuilds the album the way it was.
+func (c *GooglePhotosClient) ListAlbumItemsOrdered(albumID string) ([]RemoteMediaItem, error) {
+	var items []RemoteMediaItem
+	pageToken := ""
+
+	for {
+		body := map[string]interface{}{
+			"albumId":  albumID,
+			"pageSize": 100,
+		}
+		if pageToken != "" {
+			body["pageToken"] = pageToken
+		}
+
+		respBody, status, err := c.postJSON(c.baseURL+"/mediaItems:search", body)
+		if err != nil {
+			return nil, fmt.Errorf("search request failed: %w", err)
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("search failed: %d %s", status, string(respBody))
+		}
+
+		var result struct {
+			MediaItems []struct {
+				ID            string `json:"id"`
+				Filename      string `json:"filename"`
+				BaseURL       string `json:"baseUrl"`
+				MimeType      string `json:"mimeType"`
+				MediaMetadata struct {
+					CreationTime string `json:"creationTime"`
+					Width        string `json:"width"`
+					Height       string `json:"height"`
+				} `json:"mediaMetadata"`
+			} `json:"mediaItems"`
+			NextPageToken string `json:"nextPageToken"`
 		}
 
 		if err := json.Unmarshal(respBody, &result); err != nil {
@@ -271,12 +752,15 @@ func (c *GooglePhotosClient) ListAlbumItems(albumID string) (map[string]RemoteIt
 		}
 
 		for _, item := range result.MediaItems {
-			items[item.Filename] = RemoteItemInfo{
+			items = append(items, RemoteMediaItem{
 				ID:           item.ID,
+				Filename:     item.Filename,
+				BaseURL:      item.BaseURL,
+				MimeType:     item.MimeType,
 				CreationTime: item.MediaMetadata.CreationTime,
 				Width:        item.MediaMetadata.Width,
 				Height:       item.MediaMetadata.Height,
-			}
+			})
 		}
 
 		if result.NextPageToken == "" {
@@ -288,26 +772,62 @@ func (c *GooglePhotosClient) ListAlbumItems(albumID string) (map[string]RemoteIt
 	return items, nil
 }
 
+// DownloadMediaItem fetches the original bytes of item, appending "=d" for
+// photos or "=dv" for videos to baseUrl as required by the Google Photos
+// download convention. The caller must close the returned body.
+func (c *GooglePhotosClient) DownloadMediaItem(item RemoteMediaItem) (io.ReadCloser, error) {
+	suffix := "=d"
+	if strings.HasPrefix(item.MimeType, "video/") {
+		suffix = "=dv"
+	}
+
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", item.BaseURL+suffix, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: %d %s", resp.StatusCode, string(body))
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{c.sink().WrapReader(resp.Body), resp.Body}, nil
+}
+
+// FetchThumbnailHash downloads a small w32-h32 thumbnail of the media item
+// at baseURL and returns its dHash, so a local file can be compared against
+// a remote item that doesn't share its filename (a renamed duplicate).
+func (c *GooglePhotosClient) FetchThumbnailHash(baseURL string) (uint64, error) {
+	resp, err := c.pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", baseURL+"=w32-h32", nil)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("thumbnail request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("thumbnail download failed: %d %s", resp.StatusCode, string(body))
+	}
+	return DHashReader(resp.Body)
+}
+
 // RemoveFromAlbum removes media items from an album
 func (c *GooglePhotosClient) RemoveFromAlbum(albumID string, mediaItemIDs []string) error {
 	body := map[string]interface{}{
 		"mediaItemIds": mediaItemIDs,
 	}
-	data, _ := json.Marshal(body)
 
-	resp, err := c.httpClient.Post(
-		c.baseURL+"/albums/"+albumID+":batchRemoveMediaItems",
-		"application/json",
-		bytes.NewReader(data),
-	)
+	respBody, status, err := c.postJSON(c.baseURL+"/albums/"+albumID+":batchRemoveMediaItems", body)
 	if err != nil {
 		return fmt.Errorf("remove from album request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("remove from album failed: %d %s", resp.StatusCode, string(respBody))
+	if status != http.StatusOK {
+		return fmt.Errorf("remove from album failed: %d %s", status, string(respBody))
 	}
 
 	return nil
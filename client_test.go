@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func newTestClient(handler http.Handler) (*GooglePhotosClient, *httptest.Server) {
@@ -16,10 +23,35 @@ func newTestClient(handler http.Handler) (*GooglePhotosClient, *httptest.Server)
 	client := &GooglePhotosClient{
 		httpClient: server.Client(),
 		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
 	}
 	return client, server
 }
 
+func TestWithRetryPolicyAppliesToListAlbums(t *testing.T) {
+	var calls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+	client.WithRetryPolicy(5, time.Millisecond, 5*time.Millisecond)
+
+	if _, err := client.ListAlbums(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 2 retries before success, got %d calls", calls)
+	}
+}
+
 func TestListAlbums(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
@@ -105,7 +137,7 @@ func TestCreateAlbum(t *testing.T) {
 }
 
 func TestGetOrCreateAlbumExisting(t *testing.T) {
-	client := &GooglePhotosClient{httpClient: http.DefaultClient, baseURL: "http://unused"}
+	client := &GooglePhotosClient{httpClient: http.DefaultClient, baseURL: "http://unused", pacer: NewPacer(http.DefaultClient)}
 	existing := map[string]string{"Existing": "eid"}
 
 	id, err := client.GetOrCreateAlbum("Existing", existing)
@@ -232,6 +264,210 @@ func TestAddToAlbumPartialFailure(t *testing.T) {
 	}
 }
 
+func TestAddToAlbumWithDescriptions(t *testing.T) {
+	var gotDescriptions []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NewMediaItems []struct {
+				Description     string `json:"description"`
+				SimpleMediaItem struct {
+					UploadToken string `json:"uploadToken"`
+				} `json:"simpleMediaItem"`
+			} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i, item := range body.NewMediaItems {
+			gotDescriptions = append(gotDescriptions, item.Description)
+			results[i] = map[string]interface{}{
+				"status": map[string]interface{}{"message": "Success"},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"newMediaItemResults": results,
+		})
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	indices, err := client.AddToAlbumWithDescriptions([]string{"t1", "t2"}, "album-id", []string{"На пляже", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 2 {
+		t.Errorf("expected 2 successes, got %d", len(indices))
+	}
+	if len(gotDescriptions) != 2 || gotDescriptions[0] != "На пляже" || gotDescriptions[1] != "" {
+		t.Errorf("expected descriptions [На пляже, ''] to reach the request, got %v", gotDescriptions)
+	}
+}
+
+func TestAddToAlbumSplitsBatchesOf50(t *testing.T) {
+	var batchSizes []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NewMediaItems []struct{} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		batchSizes = append(batchSizes, len(body.NewMediaItems))
+
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i := range body.NewMediaItems {
+			results[i] = map[string]interface{}{"status": map[string]interface{}{"message": "Success"}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"newMediaItemResults": results})
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	tokens := make([]string, 120)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("t%d", i)
+	}
+
+	indices, err := client.AddToAlbum(tokens, "album-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 120 {
+		t.Errorf("expected 120 successes, got %d", len(indices))
+	}
+	if want := []int{50, 50, 20}; !reflect.DeepEqual(batchSizes, want) {
+		t.Errorf("expected batches %v, got %v", want, batchSizes)
+	}
+}
+
+func TestUploadFilesRespectsConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			cur := atomic.LoadInt32(&maxInFlight)
+			if n <= cur || atomic.CompareAndSwapInt32(&maxInFlight, cur, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("token-" + r.Header.Get("X-Goog-Upload-File-Name")))
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 8; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("f%d.jpg", i))
+		os.WriteFile(p, []byte("data"), 0644)
+		paths = append(paths, p)
+	}
+
+	results := client.UploadFiles(paths, UploadOptions{Parallel: 2}, nil)
+	if len(results) != 8 {
+		t.Fatalf("expected 8 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("unexpected error for %s: %v", r.Path, r.Err)
+		}
+	}
+	if atomic.LoadInt32(&maxInFlight) > 2 {
+		t.Errorf("expected at most 2 concurrent uploads, saw %d", maxInFlight)
+	}
+}
+
+func TestUploadFilesStreamsProgressEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("token"))
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 3; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("f%d.jpg", i))
+		os.WriteFile(p, []byte("data"), 0644)
+		paths = append(paths, p)
+	}
+
+	events := make(chan ProgressEvent, len(paths))
+	results := client.UploadFiles(paths, UploadOptions{Parallel: 4}, events)
+	close(events)
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	seen := 0
+	for range events {
+		seen++
+	}
+	if seen != 3 {
+		t.Errorf("expected 3 progress events, got %d", seen)
+	}
+}
+
+// TestUploadFilesResumableConcurrentSafe drives several files through the
+// resumable path at once (UploadFiles' own worker pool, Parallel > 1), the
+// same shape as ProcessFolder's default --parallel 4 with files over
+// --resumable-threshold. Each worker reads/writes GooglePhotosClient.resumable
+// for its own file concurrently with the others; run with -race this used to
+// flag a concurrent map read/write before resumableMu was added.
+func TestUploadFilesResumableConcurrentSafe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get("X-Goog-Upload-File-Name")
+		w.Header().Set("X-Goog-Upload-URL", "http://"+r.Host+"/uploads/"+name)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Goog-Upload-Command") == "upload, finalize" {
+			w.Write([]byte("token-" + strings.TrimPrefix(r.URL.Path, "/uploads/")))
+			return
+		}
+		_ = body
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+	// Threshold 1 forces every file through the resumable path regardless
+	// of size, so this doesn't depend on writing large fixture files.
+	client.WithResumableUploads(1, 4, map[string]ResumableState{})
+
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 16; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("f%d.mp4", i))
+		os.WriteFile(p, []byte("0123456789ABCDEF"), 0644)
+		paths = append(paths, p)
+	}
+
+	results := client.UploadFiles(paths, UploadOptions{Parallel: 8}, nil)
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("unexpected error for %s: %v", r.Path, r.Err)
+		}
+	}
+	if len(client.resumable) != 0 {
+		t.Errorf("expected all resumable sessions to be cleared after finalize, got %d left", len(client.resumable))
+	}
+}
+
 func TestListAlbumItems(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/mediaItems:search", func(w http.ResponseWriter, r *http.Request) {
@@ -311,3 +547,186 @@ func TestUploadFileError(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func TestUploadFileResumable(t *testing.T) {
+	var gotChunks []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Goog-Upload-Command") {
+		case "start":
+			if r.Header.Get("X-Goog-Upload-Protocol") != "resumable" {
+				t.Errorf("unexpected protocol: %s", r.Header.Get("X-Goog-Upload-Protocol"))
+			}
+			w.Header().Set("X-Goog-Upload-URL", "http://"+r.Host+"/uploads/session1")
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected command on /uploads: %s", r.Header.Get("X-Goog-Upload-Command"))
+		}
+	})
+	mux.HandleFunc("/uploads/session1", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotChunks = append(gotChunks, string(body))
+		cmd := r.Header.Get("X-Goog-Upload-Command")
+		if cmd == "upload, finalize" {
+			w.Write([]byte("resumable-token"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+	client.WithResumableUploads(10, 4, map[string]ResumableState{})
+
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "video.mp4")
+	os.WriteFile(fpath, []byte("0123456789AB"), 0644) // 12 bytes, 4-byte chunks
+
+	token, err := client.UploadFile(fpath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "resumable-token" {
+		t.Errorf("unexpected token: %s", token)
+	}
+	if len(gotChunks) != 3 {
+		t.Errorf("expected 3 chunks, got %d: %v", len(gotChunks), gotChunks)
+	}
+	if len(client.resumable) != 0 {
+		t.Error("expected resumable session to be cleared after finalize")
+	}
+}
+
+func TestUploadFileBelowResumableThreshold(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Upload-Protocol") != "raw" {
+			t.Errorf("expected raw protocol for small file, got %s", r.Header.Get("X-Goog-Upload-Protocol"))
+		}
+		w.Write([]byte("raw-token"))
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+	client.WithResumableUploads(1024*1024, DefaultChunkSize, map[string]ResumableState{})
+
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "photo.jpg")
+	os.WriteFile(fpath, []byte("small"), 0644)
+
+	token, err := client.UploadFile(fpath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "raw-token" {
+		t.Errorf("unexpected token: %s", token)
+	}
+}
+
+func TestUploadFileResumableForcedBelowThreshold(t *testing.T) {
+	var gotChunks []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Upload-Protocol") != "resumable" {
+			t.Errorf("unexpected protocol: %s", r.Header.Get("X-Goog-Upload-Protocol"))
+		}
+		w.Header().Set("X-Goog-Upload-URL", "http://"+r.Host+"/uploads/session1")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/uploads/session1", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotChunks = append(gotChunks, string(body))
+		if r.Header.Get("X-Goog-Upload-Command") == "upload, finalize" {
+			w.Write([]byte("resumable-token"))
+		}
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "small.jpg")
+	os.WriteFile(fpath, []byte("0123456789AB"), 0644) // 12 bytes, no WithResumableUploads threshold set
+
+	// UploadFileResumable bypasses the resumable threshold entirely and
+	// uses its own chunkSize argument rather than the client's configured
+	// default, so this exercises a per-call override.
+	token, err := client.UploadFileResumable(fpath, "", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "resumable-token" {
+		t.Errorf("unexpected token: %s", token)
+	}
+	if len(gotChunks) != 3 {
+		t.Errorf("expected 3 chunks of at most 5 bytes (5+5+2), got %d: %v", len(gotChunks), gotChunks)
+	}
+}
+
+func TestDownloadMediaItemSuffixByMimeType(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo=d", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("photo-bytes"))
+	})
+	mux.HandleFunc("/video=dv", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video-bytes"))
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	photo, err := client.DownloadMediaItem(RemoteMediaItem{BaseURL: server.URL + "/photo", MimeType: "image/jpeg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer photo.Close()
+	data, _ := io.ReadAll(photo)
+	if string(data) != "photo-bytes" {
+		t.Errorf("expected photo bytes, got %q", data)
+	}
+
+	video, err := client.DownloadMediaItem(RemoteMediaItem{BaseURL: server.URL + "/video", MimeType: "video/mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer video.Close()
+	data, _ = io.ReadAll(video)
+	if string(data) != "video-bytes" {
+		t.Errorf("expected video bytes, got %q", data)
+	}
+}
+
+func TestFetchThumbnailHashRequestsThumbnailSize(t *testing.T) {
+	img := makeTestImage(64, 64, func(x, y int) color.Color {
+		if (x/8+y/8)%2 == 0 {
+			return color.White
+		}
+		return color.Black
+	})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotPath string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/thumb=w32-h32", func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path + r.URL.RawQuery
+		w.Write(buf.Bytes())
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	hash, err := client.FetchThumbnailHash(server.URL + "/thumb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := dHash(img)
+	if hash != want {
+		t.Errorf("expected thumbnail hash %x, got %x", want, hash)
+	}
+	if gotPath != "/thumb=w32-h32" {
+		t.Errorf("expected request to use the =w32-h32 thumbnail suffix, got %q", gotPath)
+	}
+}
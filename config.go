@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 var Scopes = []string{
 	"https://www.googleapis.com/auth/photoslibrary",
 	"https://www.googleapis.com/auth/photoslibrary.readonly",
@@ -24,4 +26,20 @@ var SupportedExtensions = map[string]bool{
 }
 
 const UploadLog = ".gphotos_uploaded.json"
-const MaxFileSize = 200 * 1024 * 1024 // 200 MB
+const PhotoPipeIgnoreFile = ".photopipeignore"
+// MaxFileSize is Google Photos' own documented per-file limit for videos,
+// raised from the old single-shot upload's 200 MB now that large files go
+// through the resumable chunked protocol (see DefaultResumableThreshold
+// below). Nothing in the upload path checks files against it.
+const MaxFileSize = 10 * 1024 * 1024 * 1024 // 10 GB
+
+// Resumable upload defaults. Files at or above the resumable threshold
+// (DefaultResumableThreshold unless --resumable-threshold overrides it) are
+// sent via the chunked resumable protocol instead of a single raw POST, so a
+// network blip mid-upload only costs one chunk instead of the whole file.
+const DefaultResumableThreshold = 50 * 1024 * 1024 // 50 MB
+const DefaultChunkSize = 8 * 1024 * 1024           // 8 MiB
+
+// ResumableURLTTL mirrors Google's documented lifetime for an upload URL;
+// sessions older than this are discarded and restarted from scratch.
+const ResumableURLTTL = 7 * 24 * time.Hour
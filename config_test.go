@@ -35,7 +35,7 @@ func TestAPIBase(t *testing.T) {
 }
 
 func TestMaxFileSize(t *testing.T) {
-	if MaxFileSize != 200*1024*1024 {
+	if MaxFileSize != 10*1024*1024*1024 {
 		t.Errorf("unexpected max file size: %d", MaxFileSize)
 	}
 }
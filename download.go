@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestEntry describes one file inside an album ZIP's manifest.json, in
+// the same order the items appear in the ZIP, so a round-trip (download,
+// then re-upload elsewhere) can rebuild the album in its original order.
+type ManifestEntry struct {
+	Filename     string `json:"filename"`
+	CreationTime string `json:"creationTime"`
+	Width        string `json:"width"`
+	Height       string `json:"height"`
+	MediaItemID  string `json:"mediaItemId"`
+}
+
+// DownloadAlbum streams items into a ZIP archive named after albumTitle
+// inside outDir, alongside a manifest.json listing filename, creationTime,
+// width, height, and mediaItemId for every entry in the same order they
+// appear in the ZIP. progress may be nil.
+func DownloadAlbum(client *GooglePhotosClient, albumTitle string, items []RemoteMediaItem, outDir string, progress *ProgressReporter) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+
+	zipPath := filepath.Join(outDir, sanitizeAlbumName(albumTitle)+".zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	manifest := make([]ManifestEntry, 0, len(items))
+
+	for i, item := range items {
+		name := item.Filename
+		if name == "" {
+			name = fmt.Sprintf("item-%d", i+1)
+		}
+
+		if err := downloadOne(client, zw, item, name); err != nil {
+			fmt.Printf("     ⚠️  %s: %v\n", name, err)
+			continue
+		}
+		progress.FileDone()
+
+		manifest = append(manifest, ManifestEntry{
+			Filename:     name,
+			CreationTime: item.CreationTime,
+			Width:        item.Width,
+			Height:       item.Height,
+			MediaItemID:  item.ID,
+		})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	manifestW, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("create manifest entry: %w", err)
+	}
+	if _, err := manifestW.Write(manifestData); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	return zw.Close()
+}
+
+func downloadOne(client *GooglePhotosClient, zw *zip.Writer, item RemoteMediaItem, name string) error {
+	body, err := client.DownloadMediaItem(item)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create zip entry: %w", err)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("write zip entry: %w", err)
+	}
+	return nil
+}
+
+// sanitizeAlbumName replaces path separators (and ':') in an album title
+// with '_' so it's safe to use as a ZIP filename.
+func sanitizeAlbumName(title string) string {
+	r := strings.NewReplacer("/", "_", "\\", "_", ":", "_")
+	name := r.Replace(title)
+	if name == "" {
+		return "album"
+	}
+	return name
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeAlbumName(t *testing.T) {
+	tests := map[string]string{
+		"Vacation 2024": "Vacation 2024",
+		"Trip/To/Japan": "Trip_To_Japan",
+		`C:\Photos`:     "C__Photos",
+		"":              "album",
+	}
+	for input, want := range tests {
+		if got := sanitizeAlbumName(input); got != want {
+			t.Errorf("sanitizeAlbumName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestListAlbumItemsOrdered(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mediaItems:search", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mediaItems": []map[string]interface{}{
+				{
+					"id": "id1", "filename": "b.jpg", "baseUrl": "http://example/b", "mimeType": "image/jpeg",
+					"mediaMetadata": map[string]string{"creationTime": "2024-01-01T00:00:00Z", "width": "100", "height": "200"},
+				},
+				{
+					"id": "id2", "filename": "a.jpg", "baseUrl": "http://example/a", "mimeType": "image/jpeg",
+					"mediaMetadata": map[string]string{"creationTime": "2024-01-02T00:00:00Z", "width": "50", "height": "60"},
+				},
+			},
+		})
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	items, err := client.ListAlbumItemsOrdered("album-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	// Ordering preserved as returned by the API (not re-sorted).
+	if items[0].Filename != "b.jpg" || items[1].Filename != "a.jpg" {
+		t.Errorf("expected original order preserved, got %v, %v", items[0].Filename, items[1].Filename)
+	}
+}
+
+func TestDownloadAlbumWritesZipAndManifest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo=d", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("photo-bytes"))
+	})
+	mux.HandleFunc("/video=dv", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video-bytes"))
+	})
+
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	items := []RemoteMediaItem{
+		{ID: "id1", Filename: "a.jpg", BaseURL: server.URL + "/photo", MimeType: "image/jpeg", CreationTime: "2024-01-01T00:00:00Z", Width: "100", Height: "200"},
+		{ID: "id2", Filename: "b.mp4", BaseURL: server.URL + "/video", MimeType: "video/mp4", CreationTime: "2024-01-02T00:00:00Z", Width: "300", Height: "400"},
+	}
+
+	outDir := t.TempDir()
+	if err := DownloadAlbum(client, "My Album", items, outDir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(outDir, "My Album.zip")
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	if _, ok := files["a.jpg"]; !ok {
+		t.Error("expected a.jpg in zip")
+	}
+	if _, ok := files["b.mp4"]; !ok {
+		t.Error("expected b.mp4 in zip")
+	}
+
+	manifestFile, ok := files["manifest.json"]
+	if !ok {
+		t.Fatal("expected manifest.json in zip")
+	}
+	rc, err := manifestFile.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var manifest []ManifestEntry
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %d", len(manifest))
+	}
+	if manifest[0].Filename != "a.jpg" || manifest[0].MediaItemID != "id1" {
+		t.Errorf("unexpected first manifest entry: %+v", manifest[0])
+	}
+	if manifest[1].Filename != "b.mp4" || manifest[1].MediaItemID != "id2" {
+		t.Errorf("unexpected second manifest entry: %+v", manifest[1])
+	}
+}
+
+func TestDownloadAlbumCreatesOutDir(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo=d", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	})
+	client, server := newTestClient(mux)
+	defer server.Close()
+
+	outDir := filepath.Join(t.TempDir(), "nested", "out")
+	items := []RemoteMediaItem{{ID: "id1", Filename: "a.jpg", BaseURL: server.URL + "/photo", MimeType: "image/jpeg"}}
+
+	if err := DownloadAlbum(client, "Album", items, outDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "Album.zip")); err != nil {
+		t.Errorf("expected zip to be created: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestNewExifBatcherMissingBinary(t *testing.T) {
+	// This sandbox has no exiftool on PATH; verify we degrade gracefully
+	// (an error, not a panic) instead of failing the whole run.
+	batcher, err := NewExifBatcher()
+	if err == nil {
+		t.Skip("exiftool is installed in this environment, nothing to verify here")
+	}
+	if batcher != nil {
+		t.Error("expected nil batcher on error")
+	}
+}
+
+func TestExifBatcherNilCloseIsNoOp(t *testing.T) {
+	var b *ExifBatcher
+	if err := b.Close(); err != nil {
+		t.Errorf("expected nil Close on nil batcher, got %v", err)
+	}
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,11 +15,16 @@ import (
 )
 
 type LocalFileInfo struct {
-	Filename string
-	Size     int64
-	Date     time.Time
-	Width    int
-	Height   int
+	Filename    string
+	Size        int64
+	Date        time.Time
+	Width       int
+	Height      int
+	Favorite    bool
+	Description string
+	GPSLat      float64
+	GPSLong     float64
+	CameraModel string
 }
 
 type RemoteItemInfo struct {
@@ -25,26 +32,54 @@ type RemoteItemInfo struct {
 	CreationTime string
 	Width        string
 	Height       string
+	BaseURL      string
+	MimeType     string
 }
 
-func FindMediaFiles(folder string) ([]string, error) {
+// FindMediaFiles lists supported media files directly inside folder,
+// applying filter (include/exclude globs, size and date bounds) plus any
+// .photopipeignore found in folder. It returns the matching files and the
+// count of files that were excluded by the filter, so callers can report
+// what got skipped.
+func FindMediaFiles(folder string, filter *Filter) ([]string, int, error) {
 	entries, err := os.ReadDir(folder)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
+	ignorePatterns, err := LoadIgnoreFile(folder)
+	if err != nil {
+		return nil, 0, err
+	}
+	folderFilter := filter.WithExtraExcludes(ignorePatterns)
+
 	var files []string
+	filtered := 0
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		ext := strings.ToLower(filepath.Ext(e.Name()))
-		if SupportedExtensions[ext] {
-			files = append(files, filepath.Join(folder, e.Name()))
+		if !SupportedExtensions[ext] {
+			continue
 		}
+
+		size := int64(0)
+		var modTime time.Time
+		if info, err := e.Info(); err == nil {
+			size = info.Size()
+			modTime = info.ModTime()
+		}
+
+		if !folderFilter.Allows(e.Name(), size, modTime) {
+			filtered++
+			continue
+		}
+
+		files = append(files, filepath.Join(folder, e.Name()))
 	}
 	sort.Strings(files)
-	return files, nil
+	return files, filtered, nil
 }
 
 func FormatSize(sizeBytes int64) string {
@@ -93,11 +128,134 @@ func GetLocalFileInfo(fpath string) LocalFileInfo {
 				info.Height = v
 			}
 		}
+		if d, err := x.Get(exif.ImageDescription); err == nil {
+			if v, err := d.StringVal(); err == nil {
+				info.Description = v
+			}
+		}
+		if lat, long, err := x.LatLong(); err == nil {
+			info.GPSLat, info.GPSLong = lat, long
+		}
+		if m, err := x.Get(exif.Model); err == nil {
+			if v, err := m.StringVal(); err == nil {
+				info.CameraModel = v
+			}
+		}
+	}
+
+	// A Takeout sidecar, when present, is treated as more authoritative than
+	// EXIF/mtime - it's the source export's own record of when and where the
+	// photo was taken, which survives re-encodes that strip EXIF entirely.
+	if meta, ok := readTakeoutMetadata(fpath); ok {
+		info.Favorite = meta.Favorited.Value
+		if meta.Description != "" {
+			info.Description = meta.Description
+		}
+		if meta.GeoData.Latitude != 0 || meta.GeoData.Longitude != 0 {
+			info.GPSLat, info.GPSLong = meta.GeoData.Latitude, meta.GeoData.Longitude
+		}
+		if ts, err := strconv.ParseInt(meta.PhotoTakenTime.Timestamp, 10, 64); err == nil && ts > 0 {
+			info.Date = time.Unix(ts, 0)
+		}
 	}
 
 	return info
 }
 
+// takeoutMetadataSuffix is how Google Takeout names a photo's metadata
+// sidecar: "IMG_1234.jpg.json" next to "IMG_1234.jpg".
+const takeoutMetadataSuffix = ".json"
+
+// TakeoutMetadata is the subset of Google Takeout's per-photo JSON sidecar
+// schema this pipeline understands. It's deliberately narrow - Takeout's
+// real schema has many more fields (appVersion, imageViews, people, ...) -
+// but this covers everything the pipeline itself can round-trip: when the
+// photo was taken, where, its caption, and whether it was favorited.
+type TakeoutMetadata struct {
+	Description    string `json:"description"`
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"geoData"`
+	Favorited struct {
+		Value bool `json:"value"`
+	} `json:"favorited"`
+}
+
+// readTakeoutMetadata reads fpath's Takeout sidecar, if any. ok is false
+// when there's no sidecar or it isn't valid JSON - the common case for
+// files that didn't come from a Takeout export - in which case callers
+// should fall back to EXIF/mtime as usual.
+func readTakeoutMetadata(fpath string) (TakeoutMetadata, bool) {
+	var meta TakeoutMetadata
+	data, err := os.ReadFile(fpath + takeoutMetadataSuffix)
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
+// WriteTakeoutSidecar writes a Takeout-compatible metadata sidecar next to
+// fpath, in the same format readTakeoutMetadata reads back. This is how
+// --export-takeout lets an upload run double as a Takeout-style backup: run
+// the pipeline again against that backup later (or import it into a real
+// Google Takeout restore) and the favorite flag, capture time, description
+// and geodata all come back unchanged.
+func WriteTakeoutSidecar(fpath string, info LocalFileInfo) error {
+	var meta TakeoutMetadata
+	meta.Description = info.Description
+	meta.PhotoTakenTime.Timestamp = strconv.FormatInt(info.Date.Unix(), 10)
+	meta.GeoData.Latitude = info.GPSLat
+	meta.GeoData.Longitude = info.GPSLong
+	meta.Favorited.Value = info.Favorite
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal takeout metadata: %w", err)
+	}
+	if err := os.WriteFile(fpath+takeoutMetadataSuffix, data, 0644); err != nil {
+		return fmt.Errorf("write takeout sidecar: %w", err)
+	}
+	return nil
+}
+
+// EnrichWithExif overrides info.Date with the date exiftool extracted for
+// fpath, when available. It's a no-op when batcher is nil (exiftool missing
+// from PATH), when fpath has a Takeout sidecar with a capture time (which
+// GetLocalFileInfo already applied and which takes precedence), or when
+// extraction fails, leaving the date from GetLocalFileInfo in place.
+func EnrichWithExif(info *LocalFileInfo, fpath string, batcher *ExifBatcher) {
+	if batcher == nil {
+		return
+	}
+	if meta, ok := readTakeoutMetadata(fpath); ok {
+		if ts, err := strconv.ParseInt(meta.PhotoTakenTime.Timestamp, 10, 64); err == nil && ts > 0 {
+			return
+		}
+	}
+	date, err := batcher.DateFor(fpath)
+	if err != nil || date.IsZero() {
+		return
+	}
+	info.Date = date
+}
+
+// CanonicalUploadName builds a "YYYY-MM-DD_HHMMSS_origname.ext" name from a
+// file's capture date so Google Photos groups chronologically even when the
+// EXIF data that drove sorting isn't visible to Google's own ingestion.
+func CanonicalUploadName(info LocalFileInfo) string {
+	if info.Date.IsZero() {
+		return info.Filename
+	}
+	return fmt.Sprintf("%s_%s", info.Date.Format("2006-01-02_150405"), info.Filename)
+}
+
 func FormatRemoteDate(creationTime string) string {
 	if creationTime == "" {
 		return "?"
@@ -113,7 +271,31 @@ func FormatRemoteDate(creationTime string) string {
 	return t.Format("2006-01-02 15:04")
 }
 
-func PromptDuplicate(fpath string, remote RemoteItemInfo, reader *bufio.Reader) string {
+// RemoteCreationTimeMatches reports whether a remote item's creationTime is
+// the same moment (to the minute - Google Photos sometimes rounds) as a
+// local file's capture date. Google Photos' API doesn't expose a remote
+// item's byte size, so filename+creationTime is the strongest duplicate
+// heuristic available; uploadToAlbum uses it to auto-skip a confirmed
+// duplicate instead of prompting, reserving the prompt for filename
+// collisions where the dates don't actually line up.
+func RemoteCreationTimeMatches(creationTime string, localDate time.Time) bool {
+	if creationTime == "" || localDate.IsZero() {
+		return false
+	}
+	s := strings.Replace(creationTime, "Z", "+00:00", 1)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return false
+	}
+	return t.UTC().Truncate(time.Minute).Equal(localDate.UTC().Truncate(time.Minute))
+}
+
+// PromptDuplicate asks the user how to handle a local file that collides
+// with a remote item. similarity is a 0-100 perceptual-hash score comparing
+// the local file against the remote thumbnail, or -1 when it couldn't be
+// computed (non-image file, thumbnail fetch failed, backend doesn't
+// support it).
+func PromptDuplicate(fpath string, remote RemoteItemInfo, similarity float64, reader *bufio.Reader) string {
 	local := GetLocalFileInfo(fpath)
 	filename := filepath.Base(fpath)
 
@@ -133,16 +315,44 @@ func PromptDuplicate(fpath string, remote RemoteItemInfo, reader *bufio.Reader)
 	fmt.Printf("\n  ⚠️  Дубликат найден: %s\n", filename)
 	fmt.Printf("       Локальный:  %s  |  %s  |  %s\n", localDate, localSize, localDim)
 	fmt.Printf("       Удалённый:  %s  |  %8s  |  %s\n", remoteDate, "—", remoteDim)
+	if similarity >= 0 {
+		fmt.Printf("       Схожесть:   %.0f%%\n", similarity)
+	}
 
 	for {
-		fmt.Print("       [S]kip / [R]eplace / Re[n]ame? ")
+		fmt.Print("       [S]kip / [R]eplace / Re[n]ame / [K]eep both? ")
 		line, _ := reader.ReadString('\n')
 		choice := strings.TrimSpace(strings.ToLower(line))
 		switch choice {
-		case "s", "r", "n":
+		case "s", "r", "n", "k":
 			return choice
 		default:
-			fmt.Println("       Введите s, r или n")
+			fmt.Println("       Введите s, r, n или k")
 		}
 	}
 }
+
+// hashableImageExts are the formats Go's stdlib image package can decode,
+// and so the only ones PromptDuplicate can compute a perceptual hash for.
+var hashableImageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+}
+
+// IsHashableImage reports whether fpath's extension is one dHash can
+// actually decode.
+func IsHashableImage(fpath string) bool {
+	return hashableImageExts[strings.ToLower(filepath.Ext(fpath))]
+}
+
+// KeepBothName derives a unique filename for the "keep both" duplicate
+// choice by suffixing the content hash, so re-running on the same file
+// always produces the same name instead of an incrementing counter.
+func KeepBothName(name, fpath string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	suffix := "dup"
+	if hash, err := HashFile(fpath); err == nil && len(hash) >= 8 {
+		suffix = hash[:8]
+	}
+	return fmt.Sprintf("%s_keep_%s%s", base, suffix, ext)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestFindMediaFiles(t *testing.T) {
@@ -16,10 +17,13 @@ func TestFindMediaFiles(t *testing.T) {
 	// Create a subdirectory (should be skipped)
 	os.Mkdir(filepath.Join(dir, "subdir"), 0755)
 
-	files, err := FindMediaFiles(dir)
+	files, filtered, err := FindMediaFiles(dir, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if filtered != 0 {
+		t.Errorf("expected 0 filtered, got %d", filtered)
+	}
 
 	if len(files) != 4 {
 		t.Errorf("expected 4 media files, got %d: %v", len(files), files)
@@ -37,7 +41,7 @@ func TestFindMediaFiles(t *testing.T) {
 
 func TestFindMediaFilesEmpty(t *testing.T) {
 	dir := t.TempDir()
-	files, err := FindMediaFiles(dir)
+	files, _, err := FindMediaFiles(dir, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,7 +63,7 @@ func TestFindMediaFilesAllExtensions(t *testing.T) {
 		os.WriteFile(filepath.Join(dir, "file"+ext), []byte("x"), 0644)
 	}
 
-	files, err := FindMediaFiles(dir)
+	files, _, err := FindMediaFiles(dir, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,6 +122,132 @@ func TestGetLocalFileInfoMissing(t *testing.T) {
 	}
 }
 
+func TestGetLocalFileInfoTakeoutFavorite(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "test.jpg")
+	os.WriteFile(fpath, []byte("hello world"), 0644)
+	os.WriteFile(fpath+".json", []byte(`{"favorited": {"value": true}}`), 0644)
+
+	info := GetLocalFileInfo(fpath)
+	if !info.Favorite {
+		t.Error("expected a Takeout sidecar with favorited.value=true to mark the file favorited")
+	}
+}
+
+func TestGetLocalFileInfoNoSidecarIsNotFavorite(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "test.jpg")
+	os.WriteFile(fpath, []byte("hello world"), 0644)
+
+	info := GetLocalFileInfo(fpath)
+	if info.Favorite {
+		t.Error("expected a file with no Takeout sidecar to not be favorited")
+	}
+}
+
+func TestGetLocalFileInfoTakeoutFullMetadata(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "test.jpg")
+	os.WriteFile(fpath, []byte("hello world"), 0644)
+	os.WriteFile(fpath+".json", []byte(`{
+		"description": "На пляже",
+		"photoTakenTime": {"timestamp": "1700000000"},
+		"geoData": {"latitude": 55.75, "longitude": 37.62},
+		"favorited": {"value": true}
+	}`), 0644)
+
+	info := GetLocalFileInfo(fpath)
+	if info.Description != "На пляже" {
+		t.Errorf("expected description from sidecar, got %q", info.Description)
+	}
+	if !info.Date.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("expected photoTakenTime to override date, got %v", info.Date)
+	}
+	if info.GPSLat != 55.75 || info.GPSLong != 37.62 {
+		t.Errorf("expected geoData to populate GPS fields, got (%v, %v)", info.GPSLat, info.GPSLong)
+	}
+}
+
+func TestWriteTakeoutSidecarRoundTrips(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "test.jpg")
+	os.WriteFile(fpath, []byte("hello world"), 0644)
+
+	info := LocalFileInfo{
+		Description: "В горах",
+		Date:        time.Unix(1700000000, 0),
+		GPSLat:      43.2,
+		GPSLong:     42.4,
+		Favorite:    true,
+	}
+	if err := WriteTakeoutSidecar(fpath, info); err != nil {
+		t.Fatal(err)
+	}
+
+	roundTripped := GetLocalFileInfo(fpath)
+	if roundTripped.Description != info.Description {
+		t.Errorf("expected description to round-trip, got %q", roundTripped.Description)
+	}
+	if !roundTripped.Date.Equal(info.Date) {
+		t.Errorf("expected date to round-trip, got %v", roundTripped.Date)
+	}
+	if roundTripped.GPSLat != info.GPSLat || roundTripped.GPSLong != info.GPSLong {
+		t.Errorf("expected GPS to round-trip, got (%v, %v)", roundTripped.GPSLat, roundTripped.GPSLong)
+	}
+	if !roundTripped.Favorite {
+		t.Error("expected favorite to round-trip")
+	}
+}
+
+func TestEnrichWithExifNilBatcherIsNoOp(t *testing.T) {
+	info := LocalFileInfo{Filename: "a.jpg", Date: time.Unix(1000, 0)}
+	before := info.Date
+	EnrichWithExif(&info, "/irrelevant/a.jpg", nil)
+	if !info.Date.Equal(before) {
+		t.Error("expected date to be unchanged with a nil batcher")
+	}
+}
+
+func TestCanonicalUploadName(t *testing.T) {
+	info := LocalFileInfo{
+		Filename: "IMG_1234.jpg",
+		Date:     time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC),
+	}
+	expected := "2024-03-05_143000_IMG_1234.jpg"
+	if got := CanonicalUploadName(info); got != expected {
+		t.Errorf("CanonicalUploadName() = %q, want %q", got, expected)
+	}
+}
+
+func TestCanonicalUploadNameZeroDate(t *testing.T) {
+	info := LocalFileInfo{Filename: "IMG_1234.jpg"}
+	if got := CanonicalUploadName(info); got != "IMG_1234.jpg" {
+		t.Errorf("expected filename unchanged with zero date, got %q", got)
+	}
+}
+
+func TestRemoteCreationTimeMatches(t *testing.T) {
+	local := time.Date(2025, 2, 16, 20, 32, 22, 0, time.UTC)
+	tests := []struct {
+		name         string
+		creationTime string
+		localDate    time.Time
+		expected     bool
+	}{
+		{"exact match", "2025-02-16T20:32:22Z", local, true},
+		{"rounded to the minute still matches", "2025-02-16T20:32:51Z", local, true},
+		{"different minute doesn't match", "2025-02-16T20:33:22Z", local, false},
+		{"empty creationTime never matches", "", local, false},
+		{"zero local date never matches", "2025-02-16T20:32:22Z", time.Time{}, false},
+		{"unparseable creationTime doesn't match", "not-a-date", local, false},
+	}
+	for _, tt := range tests {
+		if got := RemoteCreationTimeMatches(tt.creationTime, tt.localDate); got != tt.expected {
+			t.Errorf("%s: RemoteCreationTimeMatches(%q, %v) = %v, want %v", tt.name, tt.creationTime, tt.localDate, got, tt.expected)
+		}
+	}
+}
+
 func TestFormatRemoteDate(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Filter decides whether a media file should be processed, combining
+// --include/--exclude globs, size bounds, and a date range. A nil Filter
+// allows everything, so callers that don't need filtering can pass nil.
+type Filter struct {
+	include []string
+	exclude []string
+	minSize int64
+	maxSize int64
+	after   time.Time
+	before  time.Time
+}
+
+func NewFilter(include, exclude []string, minSize, maxSize int64, after, before time.Time) *Filter {
+	return &Filter{
+		include: include,
+		exclude: exclude,
+		minSize: minSize,
+		maxSize: maxSize,
+		after:   after,
+		before:  before,
+	}
+}
+
+// WithExtraExcludes returns a copy of f with additional exclude patterns
+// appended, e.g. the ones read from a folder's .photopipeignore.
+func (f *Filter) WithExtraExcludes(patterns []string) *Filter {
+	if f == nil {
+		f = NewFilter(nil, nil, 0, 0, time.Time{}, time.Time{})
+	}
+	if len(patterns) == 0 {
+		return f
+	}
+	clone := *f
+	clone.exclude = append(append([]string(nil), f.exclude...), patterns...)
+	return &clone
+}
+
+// Allows reports whether a file with the given basename, size, and
+// modification time passes the filter.
+func (f *Filter) Allows(name string, size int64, modTime time.Time) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 {
+		matched := false
+		for _, p := range f.include {
+			if matchGlob(p, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if f.minSize > 0 && size < f.minSize {
+		return false
+	}
+	if f.maxSize > 0 && size > f.maxSize {
+		return false
+	}
+	if !f.after.IsZero() && modTime.Before(f.after) {
+		return false
+	}
+	if !f.before.IsZero() && modTime.After(f.before) {
+		return false
+	}
+
+	// gitignore-style: later patterns win, and "!" re-includes a file an
+	// earlier pattern excluded.
+	excluded := false
+	for _, p := range f.exclude {
+		negate := strings.HasPrefix(p, "!")
+		pattern := strings.TrimPrefix(p, "!")
+		if matchGlob(pattern, name) {
+			excluded = !negate
+		}
+	}
+
+	return !excluded
+}
+
+// matchGlob extends path.Match with "**" for recursive matches, since
+// path.Match alone can't express "any number of path segments".
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		matched, _ := filepath.Match(pattern, name)
+		return matched
+	}
+
+	parts := strings.SplitN(pattern, "**", 2)
+	prefix := strings.TrimSuffix(parts[0], "/")
+	suffix := strings.TrimPrefix(parts[1], "/")
+
+	if prefix != "" && !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if suffix == "" {
+		return true
+	}
+	matched, _ := filepath.Match(suffix, filepath.Base(name))
+	return matched || strings.HasSuffix(name, suffix)
+}
+
+// LoadIgnoreFile reads a gitignore-style .photopipeignore from folder, if
+// present: one glob pattern per line, "#" comments, blank lines skipped.
+func LoadIgnoreFile(folder string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(folder, PhotoPipeIgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// stringSliceFlag implements flag.Value for repeatable string flags like
+// --include/--exclude.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilterNilAllowsEverything(t *testing.T) {
+	var f *Filter
+	if !f.Allows("anything.jpg", 1<<30, time.Now()) {
+		t.Error("expected nil filter to allow everything")
+	}
+}
+
+func TestFilterInclude(t *testing.T) {
+	f := NewFilter([]string{"IMG_*.jpg"}, nil, 0, 0, time.Time{}, time.Time{})
+	if !f.Allows("IMG_0001.jpg", 100, time.Now()) {
+		t.Error("expected matching include pattern to be allowed")
+	}
+	if f.Allows("VID_0001.mp4", 100, time.Now()) {
+		t.Error("expected non-matching file to be excluded when include is set")
+	}
+}
+
+func TestFilterExclude(t *testing.T) {
+	f := NewFilter(nil, []string{"*.DS_Store", "Thumbs.db"}, 0, 0, time.Time{}, time.Time{})
+	if f.Allows(".DS_Store", 100, time.Now()) {
+		t.Error("expected .DS_Store to be excluded")
+	}
+	if !f.Allows("photo.jpg", 100, time.Now()) {
+		t.Error("expected unrelated file to be allowed")
+	}
+}
+
+func TestFilterExcludeNegation(t *testing.T) {
+	f := NewFilter(nil, []string{"IMG_*", "!IMG_1234.jpg"}, 0, 0, time.Time{}, time.Time{})
+	if f.Allows("IMG_0001.jpg", 100, time.Now()) {
+		t.Error("expected IMG_0001.jpg to be excluded")
+	}
+	if !f.Allows("IMG_1234.jpg", 100, time.Now()) {
+		t.Error("expected negated pattern to re-include IMG_1234.jpg")
+	}
+}
+
+func TestFilterSizeBounds(t *testing.T) {
+	f := NewFilter(nil, nil, 1000, 5000, time.Time{}, time.Time{})
+	if f.Allows("small.jpg", 500, time.Now()) {
+		t.Error("expected file below min-size to be excluded")
+	}
+	if f.Allows("big.jpg", 6000, time.Now()) {
+		t.Error("expected file above max-size to be excluded")
+	}
+	if !f.Allows("ok.jpg", 2000, time.Now()) {
+		t.Error("expected file within bounds to be allowed")
+	}
+}
+
+func TestFilterDateBounds(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	f := NewFilter(nil, nil, 0, 0, after, before)
+
+	if f.Allows("old.jpg", 100, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected file before --after to be excluded")
+	}
+	if f.Allows("future.jpg", 100, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected file after --before to be excluded")
+	}
+	if !f.Allows("mid.jpg", 100, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected file within date range to be allowed")
+	}
+}
+
+func TestMatchGlobRecursive(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.jpg", "a.jpg", true},
+		{"*.jpg", "a.png", false},
+		{"**/Thumbs.db", "Thumbs.db", true},
+		{"WhatsApp**", "WhatsApp Image 2024.jpg", true},
+		{"WhatsApp**", "IMG_0001.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadIgnoreFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	patterns, err := LoadIgnoreFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patterns != nil {
+		t.Errorf("expected nil patterns when no ignore file exists, got %v", patterns)
+	}
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "# comment\n\nThumbs.db\n*.tmp\n"
+	os.WriteFile(filepath.Join(dir, PhotoPipeIgnoreFile), []byte(content), 0644)
+
+	patterns, err := LoadIgnoreFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Thumbs.db", "*.tmp"}
+	if len(patterns) != len(expected) {
+		t.Fatalf("expected %d patterns, got %d: %v", len(expected), len(patterns), patterns)
+	}
+	for i, p := range expected {
+		if patterns[i] != p {
+			t.Errorf("pattern %d: expected %q, got %q", i, p, patterns[i])
+		}
+	}
+}
+
+func TestFindMediaFilesWithIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "Thumbs.db.jpg"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, PhotoPipeIgnoreFile), []byte("Thumbs.db.jpg\n"), 0644)
+
+	files, filtered, err := FindMediaFiles(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "a.jpg" {
+		t.Errorf("expected only a.jpg, got %v", files)
+	}
+	if filtered != 1 {
+		t.Errorf("expected 1 filtered file, got %d", filtered)
+	}
+}
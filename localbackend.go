@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// LocalMediaMetadata is the sidecar written alongside every file
+// LocalMirrorBackend copies, so a plain directory mirror still carries the
+// capture date and dimensions a photo-serving backend would otherwise track.
+type LocalMediaMetadata struct {
+	Filename     string `json:"filename"`
+	CreationTime string `json:"creationTime"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+}
+
+// LocalMirrorBackend is a PhotoBackend that writes into dest/AlbumName/ on
+// the local filesystem instead of talking to a remote API. It has no
+// concept of a remote album id, so album ids and upload tokens are just
+// paths on disk.
+type LocalMirrorBackend struct {
+	dest string
+
+	// currentAlbum is the directory GetOrCreateAlbum most recently returned.
+	// ProcessFolder only ever has one album open for upload at a time (even
+	// with --parallel, all workers in a run belong to the same folder), so
+	// a single field is enough - there's no concurrent-album case to guard.
+	currentAlbum string
+}
+
+func NewLocalMirrorBackend(dest string) (*LocalMirrorBackend, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("create dest dir: %w", err)
+	}
+	return &LocalMirrorBackend{dest: dest}, nil
+}
+
+func (b *LocalMirrorBackend) metaPath(path string) string {
+	return path + ".metajson"
+}
+
+func (b *LocalMirrorBackend) GetOrCreateAlbum(title string, existingAlbums map[string]string) (string, error) {
+	if id, ok := existingAlbums[title]; ok {
+		b.currentAlbum = id
+		return id, nil
+	}
+	return b.CreateAlbum(title)
+}
+
+func (b *LocalMirrorBackend) CreateAlbum(title string) (string, error) {
+	dir := filepath.Join(b.dest, title)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create album dir: %w", err)
+	}
+	b.currentAlbum = dir
+	return dir, nil
+}
+
+// UploadFile copies fpath into the current album directory and writes a
+// .metajson sidecar next to it. The destination path doubles as the
+// "upload token" AddToAlbum later confirms.
+func (b *LocalMirrorBackend) UploadFile(fpath string, filenameOverride string) (string, error) {
+	if b.currentAlbum == "" {
+		return "", fmt.Errorf("no album open: call GetOrCreateAlbum first")
+	}
+
+	name := filenameOverride
+	if name == "" {
+		name = filepath.Base(fpath)
+	}
+	destPath := filepath.Join(b.currentAlbum, name)
+
+	src, err := os.Open(fpath)
+	if err != nil {
+		return "", fmt.Errorf("open source file: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return "", fmt.Errorf("create dest file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("copy file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return "", fmt.Errorf("close dest file: %w", err)
+	}
+
+	info := GetLocalFileInfo(fpath)
+	meta := LocalMediaMetadata{
+		Filename:     name,
+		CreationTime: info.Date.Format("2006-01-02T15:04:05Z07:00"),
+		Width:        info.Width,
+		Height:       info.Height,
+		Size:         info.Size,
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal metadata: %w", err)
+	}
+	if err := os.WriteFile(b.metaPath(destPath), metaData, 0644); err != nil {
+		return "", fmt.Errorf("write metadata: %w", err)
+	}
+
+	return destPath, nil
+}
+
+// AddToAlbum is a no-op confirmation step: UploadFile already placed the
+// file in its album directory, so every token succeeds.
+func (b *LocalMirrorBackend) AddToAlbum(uploadTokens []string, albumID string) (map[int]bool, error) {
+	success := make(map[int]bool, len(uploadTokens))
+	for i := range uploadTokens {
+		success[i] = true
+	}
+	return success, nil
+}
+
+func (b *LocalMirrorBackend) ListAlbumItems(albumID string) (map[string]RemoteItemInfo, error) {
+	entries, err := os.ReadDir(albumID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]RemoteItemInfo{}, nil
+		}
+		return nil, fmt.Errorf("list album dir: %w", err)
+	}
+
+	items := make(map[string]RemoteItemInfo)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) == ".metajson" {
+			continue
+		}
+		path := filepath.Join(albumID, e.Name())
+		info := RemoteItemInfo{ID: path}
+		if meta, err := b.readMeta(path); err == nil {
+			info.CreationTime = meta.CreationTime
+			info.Width = strconv.Itoa(meta.Width)
+			info.Height = strconv.Itoa(meta.Height)
+		}
+		items[e.Name()] = info
+	}
+	return items, nil
+}
+
+func (b *LocalMirrorBackend) readMeta(path string) (LocalMediaMetadata, error) {
+	var meta LocalMediaMetadata
+	data, err := os.ReadFile(b.metaPath(path))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// RemoveFromAlbum deletes mediaItemIDs (paths returned by ListAlbumItems)
+// and their metadata sidecars from the album directory.
+func (b *LocalMirrorBackend) RemoveFromAlbum(albumID string, mediaItemIDs []string) error {
+	for _, path := range mediaItemIDs {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", path, err)
+		}
+		os.Remove(b.metaPath(path))
+	}
+	return nil
+}
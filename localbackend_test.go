@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalMirrorBackendUploadAndList(t *testing.T) {
+	dest := t.TempDir()
+	backend, err := NewLocalMirrorBackend(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "photo.jpg")
+	if err := os.WriteFile(srcPath, []byte("photo bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	albumID, err := backend.GetOrCreateAlbum("Vacation", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if albumID != filepath.Join(dest, "Vacation") {
+		t.Errorf("unexpected album id: %s", albumID)
+	}
+
+	token, err := backend.UploadFile(srcPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "photo bytes" {
+		t.Errorf("unexpected copied content: %s", data)
+	}
+	if _, err := os.Stat(token + ".metajson"); err != nil {
+		t.Errorf("expected metadata sidecar: %v", err)
+	}
+
+	success, err := backend.AddToAlbum([]string{token}, albumID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success[0] {
+		t.Error("expected AddToAlbum to report success")
+	}
+
+	items, err := backend.ListAlbumItems(albumID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := items["photo.jpg"]; !ok {
+		t.Error("expected photo.jpg to be listed")
+	}
+	if _, ok := items["photo.jpg.metajson"]; ok {
+		t.Error("metadata sidecar should not appear as a media item")
+	}
+
+	if err := backend.RemoveFromAlbum(albumID, []string{token}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(token); !os.IsNotExist(err) {
+		t.Error("expected file to be removed")
+	}
+	if _, err := os.Stat(token + ".metajson"); !os.IsNotExist(err) {
+		t.Error("expected metadata sidecar to be removed")
+	}
+}
+
+func TestLocalMirrorBackendUploadRequiresAlbum(t *testing.T) {
+	backend, err := NewLocalMirrorBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.UploadFile("whatever.jpg", ""); err == nil {
+		t.Error("expected an error when no album has been opened")
+	}
+}
+
+func TestLocalMirrorBackendListMissingAlbumIsEmpty(t *testing.T) {
+	backend, err := NewLocalMirrorBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := backend.ListAlbumItems(filepath.Join(t.TempDir(), "nope"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no items for a missing album dir, got %d", len(items))
+	}
+}
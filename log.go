@@ -1,62 +1,30 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sort"
+	"fmt"
+	"time"
 )
 
+// uploadLogData mirrors the legacy JSON upload log format. It's only used
+// now to decode an existing log during the one-time migration into
+// UploadStore (see store.go).
 type uploadLogData struct {
-	Uploaded []string          `json:"uploaded"`
-	Albums   map[string]string `json:"albums"`
+	Uploaded  []string                  `json:"uploaded"`
+	Albums    map[string]string         `json:"albums"`
+	Resumable map[string]ResumableState `json:"resumable,omitempty"`
 }
 
-func LoadUploadLog(exportDir string) (map[string]bool, map[string]string, error) {
-	logPath := filepath.Join(exportDir, UploadLog)
-	data, err := os.ReadFile(logPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return make(map[string]bool), make(map[string]string), nil
-		}
-		return nil, nil, err
-	}
-
-	var logData uploadLogData
-	if err := json.Unmarshal(data, &logData); err != nil {
-		return nil, nil, err
-	}
-
-	uploaded := make(map[string]bool, len(logData.Uploaded))
-	for _, path := range logData.Uploaded {
-		uploaded[path] = true
-	}
-
-	albums := logData.Albums
-	if albums == nil {
-		albums = make(map[string]string)
-	}
-	return uploaded, albums, nil
+// ResumableState tracks an in-progress resumable upload session so a crashed
+// run can pick up where it left off instead of re-uploading from byte zero.
+type ResumableState struct {
+	UploadURL string    `json:"uploadURL"`
+	Offset    int64     `json:"offset"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
-func SaveUploadLog(exportDir string, uploaded map[string]bool, albums map[string]string) error {
-	logPath := filepath.Join(exportDir, UploadLog)
-
-	paths := make([]string, 0, len(uploaded))
-	for p := range uploaded {
-		paths = append(paths, p)
-	}
-	sort.Strings(paths)
-
-	logData := uploadLogData{
-		Uploaded: paths,
-		Albums:   albums,
-	}
-
-	data, err := json.MarshalIndent(logData, "", "  ")
-	if err != nil {
-		return err
-	}
-	data = append(data, '\n')
-	return os.WriteFile(logPath, data, 0644)
+// ResumableKey identifies a resumable session by absolute path + size + mtime,
+// so that touching or replacing a file starts a fresh session rather than
+// resuming against stale bytes.
+func ResumableKey(absPath string, size int64, mtime time.Time) string {
+	return fmt.Sprintf("%s|%d|%d", absPath, size, mtime.Unix())
 }
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -15,6 +19,31 @@ func main() {
 	skipExisting := flag.Bool("skip-existing", true, "Пропускать ранее загруженные файлы")
 	credPath := flag.String("credentials", "credentials.json", "Путь к OAuth credentials")
 	tokenPath := flag.String("token", "token.json", "Путь к файлу токена")
+	resumableThreshold := flag.Int64("resumable-threshold", DefaultResumableThreshold, "Порог размера файла (байт) для возобновляемой загрузки")
+	chunkSize := flag.Int64("chunk-size", DefaultChunkSize, "Размер чанка (байт) для возобновляемой загрузки")
+	noProgress := flag.Bool("no-progress", false, "Отключить индикатор прогресса")
+	silent := flag.Bool("silent", false, "Не выводить ничего, кроме ошибок и итогов")
+	parallel := flag.Int("parallel", DefaultParallelUploads, "Количество одновременных загрузок файлов")
+	downloadMode := flag.Bool("download", false, "Скачать альбом(ы) в ZIP вместо загрузки")
+	allAlbums := flag.Bool("all", false, "Скачать все альбомы (используется с --download)")
+	outDir := flag.String("out", "download", "Папка для ZIP-архивов (используется с --download)")
+	backendName := flag.String("backend", "gphotos", "Куда загружать: gphotos, localfs или s3")
+	destDir := flag.String("dest", "", "Папка назначения (используется с --backend localfs)")
+	s3Bucket := flag.String("s3-bucket", "", "Имя S3-бакета (используется с --backend s3)")
+	s3Region := flag.String("s3-region", "us-east-1", "Регион S3 (используется с --backend s3)")
+	s3Prefix := flag.String("s3-prefix", "", "Префикс ключей в бакете (используется с --backend s3)")
+	s3Endpoint := flag.String("s3-endpoint", "", "Свой endpoint для S3-совместимого хранилища (необязательно)")
+	exportTakeout := flag.Bool("export-takeout", false, "Писать Takeout-совместимый *.json сайдкар рядом с каждым загруженным файлом")
+
+	var includePatterns, excludePatterns, albumPatternFlags stringSliceFlag
+	flag.Var(&includePatterns, "include", "Включать только файлы, подходящие под glob-шаблон (можно указать несколько раз)")
+	flag.Var(&excludePatterns, "exclude", "Исключать файлы, подходящие под glob-шаблон (можно указать несколько раз)")
+	flag.Var(&albumPatternFlags, "album-pattern", "Шаблон альбома вида by-year/{YYYY}/{album} (можно указать несколько раз; по умолчанию - имя папки)")
+	minSize := flag.Int64("min-size", 0, "Минимальный размер файла в байтах")
+	maxSize := flag.Int64("max-size", 0, "Максимальный размер файла в байтах (0 = без ограничения)")
+	afterDate := flag.String("after", "", "Включать только файлы после этой даты (YYYY-MM-DD)")
+	beforeDate := flag.String("before", "", "Включать только файлы до этой даты (YYYY-MM-DD)")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Использование: %s [опции] <папка-экспорта>\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Загружает фото и видео из подпапок в Google Photos.\n")
@@ -24,6 +53,19 @@ func main() {
 	}
 	flag.Parse()
 
+	if *downloadMode {
+		if !*allAlbums && flag.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Укажите название альбома или используйте --all")
+			os.Exit(1)
+		}
+		albumArg := ""
+		if flag.NArg() > 0 {
+			albumArg = flag.Arg(0)
+		}
+		runDownload(*credPath, *tokenPath, albumArg, *outDir, *allAlbums, *noProgress)
+		return
+	}
+
 	if flag.NArg() < 1 {
 		flag.Usage()
 		os.Exit(1)
@@ -31,6 +73,30 @@ func main() {
 
 	exportDir := flag.Arg(0)
 
+	var err error
+	var afterTime, beforeTime time.Time
+	if *afterDate != "" {
+		afterTime, err = time.Parse("2006-01-02", *afterDate)
+		if err != nil {
+			fmt.Printf("❌ Неверный формат --after: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *beforeDate != "" {
+		beforeTime, err = time.Parse("2006-01-02", *beforeDate)
+		if err != nil {
+			fmt.Printf("❌ Неверный формат --before: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	filter := NewFilter(includePatterns, excludePatterns, *minSize, *maxSize, afterTime, beforeTime)
+
+	albumPatterns, err := ParseAlbumPatterns(albumPatternFlags)
+	if err != nil {
+		fmt.Printf("❌ Неверный --album-pattern: %v\n", err)
+		os.Exit(1)
+	}
+
 	info, err := os.Stat(exportDir)
 	if err != nil || !info.IsDir() {
 		fmt.Printf("❌ Папка не найдена: %s\n", exportDir)
@@ -60,8 +126,10 @@ func main() {
 	// Pre-count files and sizes
 	totalFiles := 0
 	var totalSize int64
+	totalFiltered := 0
 	for _, folder := range subfolders {
-		files, _ := FindMediaFiles(folder)
+		files, filteredCount, _ := FindMediaFiles(folder, filter)
+		totalFiltered += filteredCount
 		for _, f := range files {
 			totalFiles++
 			if s, err := os.Stat(f); err == nil {
@@ -71,66 +139,147 @@ func main() {
 	}
 
 	// Print banner
-	fmt.Println()
-	fmt.Println("📸 Google Photos Auto-Uploader")
-	fmt.Println()
-	fmt.Printf("📂 Источник:  %s\n", exportDir)
-	fmt.Printf("📁 Альбомов:  %d\n", len(subfolders))
-	fmt.Printf("🖼️  Файлов:    %d\n", totalFiles)
-	fmt.Printf("💾 Размер:    %s\n", FormatSize(totalSize))
-	if *dryRun {
-		fmt.Println("🔍 Режим:     DRY RUN (без загрузки)")
+	if !*silent {
+		fmt.Println()
+		fmt.Println("📸 Google Photos Auto-Uploader")
+		fmt.Println()
+		fmt.Printf("📂 Источник:  %s\n", exportDir)
+		fmt.Printf("📁 Альбомов:  %d\n", len(subfolders))
+		fmt.Printf("🖼️  Файлов:    %d\n", totalFiles)
+		fmt.Printf("💾 Размер:    %s\n", FormatSize(totalSize))
+		if totalFiltered > 0 {
+			fmt.Printf("🚫 Отфильтровано: %d\n", totalFiltered)
+		}
+		if *dryRun {
+			fmt.Println("🔍 Режим:     DRY RUN (без загрузки)")
+		}
+		fmt.Println()
 	}
-	fmt.Println()
+
+	// SIGINT stops the worker pool from starting new uploads but lets
+	// whatever's already in flight finish, then the run winds down and
+	// prints its summary normally instead of dying mid-album.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	var existingAlbums map[string]string
 	canReadLibrary := true
 	stdinReader := bufio.NewReader(os.Stdin)
 
 	if !*dryRun {
-		// Authenticate
-		httpClient, err := Authenticate(*credPath, *tokenPath)
-		if err != nil {
-			fmt.Printf("❌ Ошибка авторизации: %v\n", err)
+		// Only the gphotos backend needs OAuth and a live album listing;
+		// localfs/s3 have no remote library to authenticate against.
+		var backend PhotoBackend
+		var gclient *GooglePhotosClient
+
+		switch *backendName {
+		case "gphotos":
+			c, err := NewGooglePhotosClient(ctx, *credPath, *tokenPath)
+			if err != nil {
+				fmt.Printf("❌ Ошибка авторизации: %v\n", err)
+				os.Exit(1)
+			}
+			gclient = c
+			backend = gclient
+		case "localfs":
+			b, err := NewBackend("localfs", BackendConfig{Dest: *destDir})
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(1)
+			}
+			backend = b
+		case "s3":
+			b, err := NewBackend("s3", BackendConfig{
+				S3Bucket:    *s3Bucket,
+				S3Region:    *s3Region,
+				S3Prefix:    *s3Prefix,
+				S3Endpoint:  *s3Endpoint,
+				S3AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+				S3SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			})
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(1)
+			}
+			backend = b
+		default:
+			fmt.Printf("❌ Неизвестный backend: %s\n", *backendName)
 			os.Exit(1)
 		}
 
-		client := NewGooglePhotosClient(httpClient)
-
-		// List existing albums
-		fmt.Println("📋 Загружаю список существующих альбомов...")
-		existingAlbums, err = client.ListAlbums()
+		// Open the upload store first so resumable sessions from a crashed
+		// run can be wired into the client before any uploads start. This
+		// also transparently migrates an old JSON upload log on first run.
+		store, err := OpenUploadStore(exportDir)
 		if err != nil {
-			if strings.Contains(err.Error(), "403") {
-				fmt.Println("  ⚠️  Нет доступа к списку альбомов, продолжаю без проверки")
-				canReadLibrary = false
-				existingAlbums = make(map[string]string)
-			} else {
-				fmt.Printf("❌ Ошибка получения альбомов: %v\n", err)
-				os.Exit(1)
-			}
+			fmt.Printf("❌ Ошибка открытия хранилища: %v\n", err)
+			os.Exit(1)
 		}
+		defer store.Close()
 
-		// Load upload log
-		uploadedLog, cachedAlbums, err := LoadUploadLog(exportDir)
+		cachedAlbums, err := store.Albums()
 		if err != nil {
-			fmt.Printf("⚠️  Ошибка чтения лога: %v\n", err)
-			uploadedLog = make(map[string]bool)
+			fmt.Printf("⚠️  Ошибка чтения кеша альбомов: %v\n", err)
 			cachedAlbums = make(map[string]string)
 		}
+		resumableStates, err := store.Resumable()
+		if err != nil {
+			fmt.Printf("⚠️  Ошибка чтения возобновляемых сессий: %v\n", err)
+			resumableStates = make(map[string]ResumableState)
+		}
 
-		// Merge cached albums if we can't read library
-		if !canReadLibrary && len(cachedAlbums) > 0 {
-			fmt.Printf("📝 Из кеша загружено %d альбомов\n", len(cachedAlbums))
-			for k, v := range cachedAlbums {
-				if _, exists := existingAlbums[k]; !exists {
-					existingAlbums[k] = v
+		progress := NewProgressReporter(totalFiles, totalSize, ShouldShowProgress(*noProgress || *silent))
+		if gclient != nil {
+			gclient.WithResumableUploads(*resumableThreshold, *chunkSize, resumableStates)
+			gclient.WithProgress(progress)
+		}
+
+		exifBatcher, err := NewExifBatcher()
+		if err != nil {
+			fmt.Printf("  ⚠️  Обогащение по EXIF отключено: %v\n", err)
+			exifBatcher = nil
+		} else {
+			defer exifBatcher.Close()
+		}
+
+		if gclient != nil {
+			// List existing albums
+			if !*silent {
+				fmt.Println("📋 Загружаю список существующих альбомов...")
+			}
+			existingAlbums, err = gclient.ListAlbums()
+			if err != nil {
+				if strings.Contains(err.Error(), "403") {
+					if !*silent {
+						fmt.Println("  ⚠️  Нет доступа к списку альбомов, продолжаю без проверки")
+					}
+					canReadLibrary = false
+					existingAlbums = make(map[string]string)
+				} else {
+					fmt.Printf("❌ Ошибка получения альбомов: %v\n", err)
+					os.Exit(1)
 				}
 			}
+
+			// Merge cached albums if we can't read library
+			if !canReadLibrary && len(cachedAlbums) > 0 {
+				if !*silent {
+					fmt.Printf("📝 Из кеша загружено %d альбомов\n", len(cachedAlbums))
+				}
+				for k, v := range cachedAlbums {
+					if _, exists := existingAlbums[k]; !exists {
+						existingAlbums[k] = v
+					}
+				}
+			}
+		} else {
+			// localfs/s3 can always list what's already there, so there's
+			// no "can't read library" fallback to worry about.
+			existingAlbums = cachedAlbums
 		}
 
-		if len(uploadedLog) > 0 {
-			fmt.Printf("📝 В логе %d ранее загруженных файлов\n", len(uploadedLog))
+		if n := store.UploadedCount(); n > 0 && !*silent {
+			fmt.Printf("📝 В хранилище %d ранее загруженных файлов\n", n)
 		}
 
 		// Process folders
@@ -138,9 +287,13 @@ func main() {
 		totalSkipped := 0
 
 		for _, folder := range subfolders {
+			if ctx.Err() != nil {
+				break
+			}
 			result, err := ProcessFolder(
-				client, folder, existingAlbums, uploadedLog,
-				*skipExisting, false, canReadLibrary, stdinReader,
+				backend, folder, existingAlbums, store,
+				*skipExisting, false, canReadLibrary, stdinReader, exifBatcher, filter, *parallel,
+				*silent, ctx, albumPatterns, *exportTakeout,
 			)
 			if err != nil {
 				fmt.Printf("  ⚠️  Ошибка: %v\n", err)
@@ -149,9 +302,18 @@ func main() {
 			totalSkipped += result.Skipped
 		}
 
-		// Save upload log
-		if err := SaveUploadLog(exportDir, uploadedLog, existingAlbums); err != nil {
-			fmt.Printf("⚠️  Не удалось сохранить лог: %v\n", err)
+		progress.Finish()
+
+		// Cache the album ids we saw this run and sync any resumable
+		// sessions still in flight; per-file uploads were already recorded
+		// incrementally as they completed.
+		for title, id := range existingAlbums {
+			if err := store.SetAlbum(title, id); err != nil {
+				fmt.Printf("⚠️  Не удалось сохранить альбом в кеш: %v\n", err)
+			}
+		}
+		if err := store.SyncResumable(resumableStates); err != nil {
+			fmt.Printf("⚠️  Не удалось сохранить возобновляемые сессии: %v\n", err)
 		}
 
 		// Print summary
@@ -160,17 +322,23 @@ func main() {
 		fmt.Printf("   ✅ Загружено:  %d файлов\n", totalAdded)
 		fmt.Printf("   ⏭️  Пропущено: %d файлов\n", totalSkipped)
 	} else {
-		// Dry run mode
-		uploadedLog, _, err := LoadUploadLog(exportDir)
+		// Dry run mode. Open the store read-only in spirit: ProcessFolder
+		// still calls IsUploaded to report what would be skipped, but with
+		// dryRun=true it never calls MarkUploaded.
+		store, err := OpenUploadStore(exportDir)
 		if err != nil {
-			uploadedLog = make(map[string]bool)
+			fmt.Printf("⚠️  Ошибка открытия хранилища: %v\n", err)
+			store = nil
+		} else {
+			defer store.Close()
 		}
 
 		totalSkipped := 0
 		for _, folder := range subfolders {
 			result, _ := ProcessFolder(
-				nil, folder, nil, uploadedLog,
-				*skipExisting, true, false, nil,
+				nil, folder, nil, store,
+				*skipExisting, true, false, nil, nil, filter, *parallel,
+				*silent, ctx, albumPatterns, false,
 			)
 			totalSkipped += result.Skipped
 		}
@@ -180,3 +348,78 @@ func main() {
 		fmt.Printf("   ⏭️  Пропущено: %d файлов\n", totalSkipped)
 	}
 }
+
+// runDownload implements --download: the reverse of the usual pipeline. It
+// fetches one album (or every album with --all) and streams each into a
+// ZIP archive plus a manifest.json in outDir.
+func runDownload(credPath, tokenPath, albumArg, outDir string, allAlbums bool, noProgress bool) {
+	client, err := NewGooglePhotosClient(context.Background(), credPath, tokenPath)
+	if err != nil {
+		fmt.Printf("❌ Ошибка авторизации: %v\n", err)
+		os.Exit(1)
+	}
+
+	albums, err := client.ListAlbums()
+	if err != nil {
+		fmt.Printf("❌ Ошибка получения альбомов: %v\n", err)
+		os.Exit(1)
+	}
+
+	targets := make(map[string]string)
+	if allAlbums {
+		targets = albums
+	} else {
+		id, ok := albums[albumArg]
+		if !ok {
+			fmt.Printf("❌ Альбом не найден: %s\n", albumArg)
+			os.Exit(1)
+		}
+		targets[albumArg] = id
+	}
+
+	titles := make([]string, 0, len(targets))
+	for title := range targets {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	fmt.Println()
+	fmt.Println("📥 Скачивание альбомов Google Photos")
+	fmt.Println()
+	fmt.Printf("📁 Альбомов: %d\n", len(titles))
+	fmt.Printf("📂 Куда:     %s\n", outDir)
+	fmt.Println()
+
+	// List every album's items up front so the progress bar knows the
+	// total item count before the first byte is downloaded.
+	itemsByTitle := make(map[string][]RemoteMediaItem, len(titles))
+	totalItems := 0
+	for _, title := range titles {
+		items, err := client.ListAlbumItemsOrdered(targets[title])
+		if err != nil {
+			fmt.Printf("  ⚠️  %s: %v\n", title, err)
+			continue
+		}
+		itemsByTitle[title] = items
+		totalItems += len(items)
+	}
+
+	progress := NewProgressReporter(totalItems, 0, ShouldShowProgress(noProgress))
+	client.WithProgress(progress)
+
+	for _, title := range titles {
+		items, ok := itemsByTitle[title]
+		if !ok {
+			continue
+		}
+		fmt.Printf("  📦 %s (%d файлов)\n", title, len(items))
+		if err := DownloadAlbum(client, title, items, outDir, progress); err != nil {
+			fmt.Printf("  ⚠️  Ошибка: %v\n", err)
+		}
+	}
+
+	progress.Finish()
+
+	fmt.Println()
+	fmt.Println("📊 Готово")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pacer paces and retries HTTP calls against the Google Photos API, in the
+// spirit of rclone's lib/pacer: it keeps a single adaptive sleep interval
+// that doubles on throttling and decays geometrically on success, so a run
+// slows down only as much as the API actually demands.
+type Pacer struct {
+	client *http.Client
+
+	mu          sync.Mutex
+	interval    time.Duration
+	minInterval time.Duration
+	maxInterval time.Duration
+	maxRetries  int
+}
+
+const (
+	pacerMinInterval = 100 * time.Millisecond
+	pacerMaxInterval = 2 * time.Minute
+	pacerMaxRetries  = 5
+)
+
+func NewPacer(client *http.Client) *Pacer {
+	return &Pacer{
+		client:      client,
+		interval:    pacerMinInterval,
+		minInterval: pacerMinInterval,
+		maxInterval: pacerMaxInterval,
+		maxRetries:  pacerMaxRetries,
+	}
+}
+
+// WithPolicy overrides the pacer's retry/backoff parameters in place.
+// maxAttempts is the total number of tries per call including the first
+// (so maxAttempts-1 is the maximum number of retries), base is the starting
+// backoff interval, and capInterval is the ceiling it won't grow past.
+// Zero/negative values leave the corresponding default untouched.
+func (p *Pacer) WithPolicy(maxAttempts int, base, capInterval time.Duration) *Pacer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if maxAttempts > 0 {
+		p.maxRetries = maxAttempts - 1
+	}
+	if base > 0 {
+		p.minInterval = base
+		p.interval = base
+	}
+	if capInterval > 0 {
+		p.maxInterval = capInterval
+	}
+	return p
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(header); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func (p *Pacer) sleep() {
+	p.mu.Lock()
+	d := p.interval
+	p.mu.Unlock()
+	if d > 0 {
+		// Full jitter: a random sleep in [0, d) rather than a fixed d, so
+		// concurrent workers retrying after the same throttled response
+		// don't all wake up and hammer the API in lockstep.
+		time.Sleep(time.Duration(rand.Int63n(int64(d))))
+	}
+}
+
+// grow doubles the interval on a throttled response, widening further if the
+// server's Retry-After asked for longer than that.
+func (p *Pacer) grow(retryAfter time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval *= 2
+	if retryAfter > p.interval {
+		p.interval = retryAfter
+	}
+	if p.interval > p.maxInterval {
+		p.interval = p.maxInterval
+	}
+}
+
+// decay shrinks the interval back towards the minimum after a success.
+func (p *Pacer) decay() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = p.interval / 2
+	if p.interval < p.minInterval {
+		p.interval = p.minInterval
+	}
+}
+
+// Do executes newReq (built fresh on every attempt, since a request body
+// can't be replayed) with pacing and retries. It retries on network errors
+// and on 429/5xx responses, honoring Retry-After when present. A non-429
+// 4xx response is returned to the caller without being retried.
+func (p *Pacer) Do(newReq func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		p.sleep()
+
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			lastErr = err
+			p.grow(0)
+			continue
+		}
+
+		if isRetryableStatus(resp.StatusCode) {
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("retryable status %d", resp.StatusCode)
+			p.grow(retryAfter)
+			if retryAfter > 0 {
+				// sleep() jitters below the interval, so on its own it could
+				// retry sooner than the server asked; wait out Retry-After
+				// (capped at maxInterval) before the next attempt.
+				p.mu.Lock()
+				if retryAfter > p.maxInterval {
+					retryAfter = p.maxInterval
+				}
+				p.mu.Unlock()
+				time.Sleep(retryAfter)
+			}
+			continue
+		}
+
+		p.decay()
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("gave up after %d attempts: %w", p.maxRetries+1, lastErr)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPacerRetriesOn429(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.minInterval = time.Millisecond
+	pacer.interval = time.Millisecond
+	pacer.maxInterval = 10 * time.Millisecond
+
+	resp, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls (2 throttled + 1 success), got %d", calls)
+	}
+}
+
+func TestPacerHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.minInterval = time.Millisecond
+	pacer.interval = time.Millisecond
+
+	resp, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestPacerGivesUpAfterMaxRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.minInterval = time.Millisecond
+	pacer.interval = time.Millisecond
+	pacer.maxInterval = 5 * time.Millisecond
+	pacer.maxRetries = 2
+
+	_, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err == nil {
+		t.Error("expected error after exhausting retries")
+	}
+}
+
+func TestPacerWithPolicyCountsAttempts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.WithPolicy(5, time.Millisecond, 5*time.Millisecond)
+
+	resp, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls != 3 {
+		t.Errorf("expected 3 attempts (2 failures + 1 success), got %d", calls)
+	}
+}
+
+func TestPacerWithPolicyGivesUpAtMaxAttempts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.WithPolicy(3, time.Millisecond, 5*time.Millisecond)
+
+	_, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err == nil {
+		t.Error("expected error after exhausting attempts")
+	}
+	if calls != 3 {
+		t.Errorf("expected exactly 3 attempts for maxAttempts=3, got %d", calls)
+	}
+}
+
+func TestPacerDoesNotRetryNon429ClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	pacer := NewPacer(server.Client())
+	pacer.minInterval = time.Millisecond
+	pacer.interval = time.Millisecond
+
+	resp, err := pacer.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 to be surfaced, got %d", resp.StatusCode)
+	}
+	if calls != 1 {
+		t.Errorf("expected exactly 1 call for a non-retryable 4xx, got %d", calls)
+	}
+}
+
+func TestParseRetryAfterSeconds(t *testing.T) {
+	d := parseRetryAfter("5")
+	if d != 5*time.Second {
+		t.Errorf("expected 5s, got %v", d)
+	}
+}
+
+func TestPacerSleepJittersBelowInterval(t *testing.T) {
+	pacer := NewPacer(http.DefaultClient)
+	pacer.interval = 20 * time.Millisecond
+
+	// Not a statistical test - just confirms sleep() doesn't always burn
+	// the full interval, i.e. it's jittered rather than fixed.
+	var sawShort bool
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		pacer.sleep()
+		if time.Since(start) < 15*time.Millisecond {
+			sawShort = true
+			break
+		}
+	}
+	if !sawShort {
+		t.Error("expected at least one jittered sleep shorter than the full interval")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AlbumPattern is a compiled destination-album template, in the spirit of
+// rclone's googlephotos "pattern" directories: a path like
+// "by-year/{YYYY}/{album}" resolves per-file into a concrete album name
+// using that file's capture date, source folder, or camera model (for
+// "{Camera}"), optionally gated by a predicate like "[favorite:true]" so
+// e.g. a "favorites" pattern only collects files the source marked as
+// favorited.
+type AlbumPattern struct {
+	Raw          string
+	parts        []patternPart
+	favoriteOnly bool
+}
+
+// patternPart is either a literal string or one of the {album}/{YYYY}/{MM}/
+// {DD}/{Camera} tokens; exactly one of the two fields is set.
+type patternPart struct {
+	literal string
+	token   string
+}
+
+var albumPatternTokens = map[string]bool{
+	"album": true, "YYYY": true, "MM": true, "DD": true, "Camera": true,
+}
+
+// ParseAlbumPattern compiles a single --album-pattern value, e.g.
+// "by-month/{YYYY}-{MM}" or "favorites[favorite:true]".
+func ParseAlbumPattern(raw string) (AlbumPattern, error) {
+	template := raw
+	favoriteOnly := false
+
+	if i := strings.IndexByte(template, '['); i != -1 {
+		if !strings.HasSuffix(template, "]") {
+			return AlbumPattern{}, fmt.Errorf("album pattern %q: predicate must be closed with ]", raw)
+		}
+		predicate := template[i+1 : len(template)-1]
+		template = template[:i]
+		switch predicate {
+		case "favorite:true":
+			favoriteOnly = true
+		default:
+			return AlbumPattern{}, fmt.Errorf("album pattern %q: unknown predicate %q", raw, predicate)
+		}
+	}
+
+	parts, err := tokenizeAlbumPattern(template)
+	if err != nil {
+		return AlbumPattern{}, fmt.Errorf("album pattern %q: %w", raw, err)
+	}
+
+	return AlbumPattern{Raw: raw, parts: parts, favoriteOnly: favoriteOnly}, nil
+}
+
+// ParseAlbumPatterns compiles every --album-pattern flag value given on the
+// command line. A nil/empty result tells ProcessFolder to fall back to the
+// single "folder name is the album" behavior it always had.
+func ParseAlbumPatterns(raw []string) ([]AlbumPattern, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	patterns := make([]AlbumPattern, 0, len(raw))
+	for _, r := range raw {
+		p, err := ParseAlbumPattern(r)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func tokenizeAlbumPattern(template string) ([]patternPart, error) {
+	var parts []patternPart
+	for len(template) > 0 {
+		i := strings.IndexByte(template, '{')
+		if i == -1 {
+			parts = append(parts, patternPart{literal: template})
+			break
+		}
+		if i > 0 {
+			parts = append(parts, patternPart{literal: template[:i]})
+		}
+		j := strings.IndexByte(template[i:], '}')
+		if j == -1 {
+			return nil, fmt.Errorf("unterminated {token} starting at %q", template[i:])
+		}
+		token := template[i+1 : i+j]
+		if !albumPatternTokens[token] {
+			return nil, fmt.Errorf("unknown token {%s}", token)
+		}
+		parts = append(parts, patternPart{token: token})
+		template = template[i+j+1:]
+	}
+	return parts, nil
+}
+
+// Resolve applies p to a file (its capture date via info, and the name of
+// the source folder it's being uploaded from) and returns the destination
+// album name. ok is false when a predicate excludes the file (e.g.
+// favorite:true on a file that isn't favorited), in which case name is
+// meaningless.
+func (p AlbumPattern) Resolve(info LocalFileInfo, sourceAlbum string) (name string, ok bool) {
+	if p.favoriteOnly && !info.Favorite {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, part := range p.parts {
+		if part.token == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		switch part.token {
+		case "album":
+			b.WriteString(sourceAlbum)
+		case "YYYY":
+			b.WriteString(info.Date.Format("2006"))
+		case "MM":
+			b.WriteString(info.Date.Format("01"))
+		case "DD":
+			b.WriteString(info.Date.Format("02"))
+		case "Camera":
+			b.WriteString(info.CameraModel)
+		}
+	}
+	return b.String(), true
+}
+
+// ResolveAlbumsForFile runs every pattern against info and returns the
+// (deduplicated, in pattern order) set of destination albums the file
+// should be uploaded into. A file matching zero patterns (e.g. it isn't
+// favorited and every pattern requires that) is uploaded nowhere - that's
+// how "favorites" can be the only pattern given without every other photo
+// also leaking into it.
+func ResolveAlbumsForFile(patterns []AlbumPattern, info LocalFileInfo, sourceAlbum string) []string {
+	seen := make(map[string]bool, len(patterns))
+	var albums []string
+	for _, p := range patterns {
+		name, ok := p.Resolve(info, sourceAlbum)
+		if !ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		albums = append(albums, name)
+	}
+	return albums
+}
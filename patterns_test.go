@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlbumPatternTokens(t *testing.T) {
+	p, err := ParseAlbumPattern("by-year/{YYYY}/{album}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := LocalFileInfo{Date: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)}
+	name, ok := p.Resolve(info, "Vacation")
+	if !ok {
+		t.Fatal("expected pattern without predicate to always match")
+	}
+	if name != "by-year/2024/Vacation" {
+		t.Errorf("got %q", name)
+	}
+}
+
+func TestParseAlbumPatternMonth(t *testing.T) {
+	p, err := ParseAlbumPattern("by-month/{YYYY}-{MM}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := LocalFileInfo{Date: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)}
+	name, _ := p.Resolve(info, "Vacation")
+	if name != "by-month/2024-03" {
+		t.Errorf("got %q", name)
+	}
+}
+
+func TestParseAlbumPatternCamera(t *testing.T) {
+	p, err := ParseAlbumPattern("by-camera/{Camera}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := LocalFileInfo{CameraModel: "Canon EOS R5"}
+	name, _ := p.Resolve(info, "Vacation")
+	if name != "by-camera/Canon EOS R5" {
+		t.Errorf("got %q", name)
+	}
+}
+
+func TestParseAlbumPatternUnknownToken(t *testing.T) {
+	if _, err := ParseAlbumPattern("{unknown}"); err == nil {
+		t.Error("expected an error for an unknown token")
+	}
+}
+
+func TestParseAlbumPatternUnterminatedToken(t *testing.T) {
+	if _, err := ParseAlbumPattern("by-year/{YYYY"); err == nil {
+		t.Error("expected an error for an unterminated token")
+	}
+}
+
+func TestParseAlbumPatternFavoritePredicate(t *testing.T) {
+	p, err := ParseAlbumPattern("favorites[favorite:true]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.Resolve(LocalFileInfo{Favorite: false}, "Vacation"); ok {
+		t.Error("expected a non-favorited file to be excluded")
+	}
+	name, ok := p.Resolve(LocalFileInfo{Favorite: true}, "Vacation")
+	if !ok || name != "favorites" {
+		t.Errorf("expected favorited file to resolve to %q, got %q (ok=%v)", "favorites", name, ok)
+	}
+}
+
+func TestParseAlbumPatternUnknownPredicate(t *testing.T) {
+	if _, err := ParseAlbumPattern("favorites[rating:5]"); err == nil {
+		t.Error("expected an error for an unknown predicate")
+	}
+}
+
+func TestParseAlbumPatternsEmptyIsNil(t *testing.T) {
+	patterns, err := ParseAlbumPatterns(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patterns != nil {
+		t.Errorf("expected nil patterns for no --album-pattern flags, got %v", patterns)
+	}
+}
+
+func TestResolveAlbumsForFileNoPatternsIsUnused(t *testing.T) {
+	// ResolveAlbumsForFile is only ever called once patterns is non-empty -
+	// bucketByAlbum in upload.go short-circuits to the source folder name
+	// before calling it - but it should still behave sanely given none.
+	albums := ResolveAlbumsForFile(nil, LocalFileInfo{}, "Vacation")
+	if len(albums) != 0 {
+		t.Errorf("expected no patterns to resolve to no albums, got %v", albums)
+	}
+}
+
+func TestResolveAlbumsForFileMultiplePatternsAndDedup(t *testing.T) {
+	yearPattern, _ := ParseAlbumPattern("by-year/{YYYY}")
+	favPattern, _ := ParseAlbumPattern("favorites[favorite:true]")
+	duplicateYearPattern, _ := ParseAlbumPattern("by-year/{YYYY}")
+
+	info := LocalFileInfo{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Favorite: true}
+	albums := ResolveAlbumsForFile([]AlbumPattern{yearPattern, favPattern, duplicateYearPattern}, info, "Vacation")
+
+	if len(albums) != 2 {
+		t.Fatalf("expected 2 deduplicated albums, got %v", albums)
+	}
+	if albums[0] != "by-year/2024" || albums[1] != "favorites" {
+		t.Errorf("expected [by-year/2024 favorites] in pattern order, got %v", albums)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// dHashWidth x dHashHeight is the grid dHash resizes an image down to before
+// comparing adjacent pixel brightness. 9x8 gives 8x8=64 comparisons, one per bit of
+// the returned hash.
+const dHashWidth, dHashHeight = 9, 8
+
+// dHash computes a difference hash: the image is shrunk to 9x8 grayscale,
+// and each bit records whether a pixel is brighter than its right neighbor.
+// Unlike a cryptographic hash, small edits (re-encoding, mild cropping,
+// resizing) barely change the result, so it's suited to "is this the same
+// photo, possibly re-saved" comparisons rather than exact-byte matching.
+func dHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	small := image.NewGray(image.Rect(0, 0, dHashWidth, dHashHeight))
+	for y := 0; y < dHashHeight; y++ {
+		srcY := bounds.Min.Y + y*bounds.Dy()/dHashHeight
+		for x := 0; x < dHashWidth; x++ {
+			srcX := bounds.Min.X + x*bounds.Dx()/dHashWidth
+			small.Set(x, y, color.GrayModel.Convert(img.At(srcX, srcY)))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			left := small.GrayAt(x, y).Y
+			right := small.GrayAt(x+1, y).Y
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// DHashReader decodes an image from r and returns its dHash.
+func DHashReader(r io.Reader) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return dHash(img), nil
+}
+
+// DHashFile decodes the image at fpath and returns its dHash.
+func DHashFile(fpath string) (uint64, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return DHashReader(f)
+}
+
+// HammingDistance counts the differing bits between two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// SimilarityPercent converts a Hamming distance over a 64-bit hash into a
+// 0-100 similarity score, where 100 means identical.
+func SimilarityPercent(a, b uint64) float64 {
+	return 100 * float64(64-HammingDistance(a, b)) / 64
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestImage(w, h int, fill func(x, y int) color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	return img
+}
+
+func TestDHashIdenticalImagesMatch(t *testing.T) {
+	img := makeTestImage(64, 64, func(x, y int) color.Color {
+		if (x/8+y/8)%2 == 0 {
+			return color.White
+		}
+		return color.Black
+	})
+
+	h1 := dHash(img)
+	h2 := dHash(img)
+	if h1 != h2 {
+		t.Errorf("expected identical images to hash the same, got %x vs %x", h1, h2)
+	}
+	if SimilarityPercent(h1, h2) != 100 {
+		t.Errorf("expected 100%% similarity for identical hashes")
+	}
+}
+
+func TestDHashDistinctImagesDiffer(t *testing.T) {
+	checkerboard := makeTestImage(64, 64, func(x, y int) color.Color {
+		if (x/8+y/8)%2 == 0 {
+			return color.White
+		}
+		return color.Black
+	})
+	solid := makeTestImage(64, 64, func(x, y int) color.Color {
+		return color.Gray{Y: 128}
+	})
+
+	h1 := dHash(checkerboard)
+	h2 := dHash(solid)
+	if h1 == h2 {
+		t.Error("expected visually distinct images to hash differently")
+	}
+	if sim := SimilarityPercent(h1, h2); sim >= 100 {
+		t.Errorf("expected similarity below 100%%, got %.0f", sim)
+	}
+}
+
+func TestDHashFileJPEGAndPNGAgreeOnSameImage(t *testing.T) {
+	img := makeTestImage(64, 64, func(x, y int) color.Color {
+		if (x/8+y/8)%2 == 0 {
+			return color.White
+		}
+		return color.Black
+	})
+
+	dir := t.TempDir()
+	jpegPath := filepath.Join(dir, "a.jpg")
+	pngPath := filepath.Join(dir, "a.png")
+
+	var jpegBuf, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(jpegPath, jpegBuf.Bytes(), 0644)
+	os.WriteFile(pngPath, pngBuf.Bytes(), 0644)
+
+	jpegHash, err := DHashFile(jpegPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pngHash, err := DHashFile(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sim := SimilarityPercent(jpegHash, pngHash); sim < 90 {
+		t.Errorf("expected the same image re-encoded as jpeg/png to score highly similar, got %.0f%%", sim)
+	}
+}
+
+func TestHammingDistanceZeroForEqualHashes(t *testing.T) {
+	if d := HammingDistance(0xABCD, 0xABCD); d != 0 {
+		t.Errorf("expected 0 distance for equal hashes, got %d", d)
+	}
+	if d := HammingDistance(0, ^uint64(0)); d != 64 {
+		t.Errorf("expected max distance of 64 for fully inverted hashes, got %d", d)
+	}
+}
+
+func TestIsHashableImage(t *testing.T) {
+	cases := map[string]bool{
+		"photo.jpg": true, "photo.JPEG": true, "photo.png": true, "photo.gif": true,
+		"photo.heic": false, "video.mp4": false, "photo.cr2": false,
+	}
+	for name, want := range cases {
+		if got := IsHashableImage(name); got != want {
+			t.Errorf("IsHashableImage(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestKeepBothNameIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "photo.jpg")
+	os.WriteFile(fpath, []byte("some bytes"), 0644)
+
+	name1 := KeepBothName("photo.jpg", fpath)
+	name2 := KeepBothName("photo.jpg", fpath)
+	if name1 != name2 {
+		t.Errorf("expected KeepBothName to be deterministic, got %q and %q", name1, name2)
+	}
+	if filepath.Ext(name1) != ".jpg" {
+		t.Errorf("expected extension to be preserved, got %q", name1)
+	}
+}
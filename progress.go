@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/mattn/go-isatty"
+)
+
+// ProgressSink is the subscriber interface ProcessFolder and
+// GooglePhotosClient report progress through. ProgressReporter (below) is
+// the cheggaaa/pb-backed CLI implementation; the seam exists so a future
+// non-CLI consumer (JSON events over a pipe, a TUI) can subscribe to the
+// same calls without touching the upload pipeline itself.
+type ProgressSink interface {
+	// FileStarted resets the per-file bar to track a new upload of size
+	// bytes. name is for display only.
+	FileStarted(name string, size int64)
+	// WrapReader wraps r so bytes read from it advance both the per-file
+	// and aggregate byte bars.
+	WrapReader(r io.Reader) io.Reader
+	// FileDone advances the file-count bar by one completed file.
+	FileDone()
+	// Finish stops rendering and flushes the bars.
+	Finish()
+}
+
+var _ ProgressSink = (*ProgressReporter)(nil)
+
+// ProgressReporter renders a file-count bar, an aggregate byte-transfer bar
+// (with speed and ETA), and a per-file byte bar for whichever upload last
+// called FileStarted, using github.com/cheggaaa/pb/v3. It is a no-op
+// (including on a nil receiver) when disabled, so callers don't need to
+// branch on whether progress reporting is on.
+//
+// With --parallel > 1, multiple uploads are in flight at once but the
+// per-file bar can only track one of them at a time; it shows whichever
+// file most recently started, which is still useful as an "upload is
+// making progress" indicator even if it isn't attributable to one file.
+type ProgressReporter struct {
+	enabled bool
+	pool    *pb.Pool
+	fileBar *pb.ProgressBar
+	byteBar *pb.ProgressBar
+	curBar  *pb.ProgressBar
+}
+
+// ShouldShowProgress decides whether to render progress bars: never with
+// --no-progress or --silent, and never when stdout isn't a TTY so CI logs
+// stay clean.
+func ShouldShowProgress(noProgress bool) bool {
+	if noProgress {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdout.Fd())
+}
+
+func NewProgressReporter(totalFiles int, totalSize int64, enabled bool) *ProgressReporter {
+	if !enabled || totalFiles == 0 {
+		return &ProgressReporter{}
+	}
+
+	fileBar := pb.New(totalFiles)
+	fileBar.SetTemplateString(`Файлы: {{counters . }} {{bar . }} {{percent . }}`)
+
+	byteBar := pb.New64(totalSize)
+	byteBar.Set(pb.Bytes, true)
+	byteBar.SetTemplateString(`Объём: {{counters . }} {{bar . }} {{speed . }} ETA {{etime . }}`)
+
+	curBar := pb.New64(0)
+	curBar.Set(pb.Bytes, true)
+	curBar.SetTemplateString(`Текущий: {{counters . }} {{bar . }} {{speed . }}`)
+
+	pool, err := pb.StartPool(fileBar, byteBar, curBar)
+	if err != nil {
+		return &ProgressReporter{}
+	}
+
+	return &ProgressReporter{enabled: true, pool: pool, fileBar: fileBar, byteBar: byteBar, curBar: curBar}
+}
+
+// FileStarted resets the per-file bar to track a new upload of size bytes.
+func (p *ProgressReporter) FileStarted(name string, size int64) {
+	if p == nil || !p.enabled {
+		return
+	}
+	p.curBar.SetCurrent(0)
+	p.curBar.SetTotal(size)
+}
+
+// WrapReader wraps r so bytes read from it (e.g. while streaming a file to
+// the upload endpoint) tick the byte bars in real time.
+func (p *ProgressReporter) WrapReader(r io.Reader) io.Reader {
+	if p == nil || !p.enabled {
+		return r
+	}
+	return p.curBar.NewProxyReader(p.byteBar.NewProxyReader(r))
+}
+
+// FileDone advances the file-count bar by one, e.g. on each successful
+// batchCreate result.
+func (p *ProgressReporter) FileDone() {
+	if p == nil || !p.enabled {
+		return
+	}
+	p.fileBar.Increment()
+}
+
+func (p *ProgressReporter) Finish() {
+	if p == nil || !p.enabled {
+		return
+	}
+	p.pool.Stop()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgressReporterDisabledIsNoOp(t *testing.T) {
+	p := NewProgressReporter(10, 1000, false)
+
+	p.FileStarted("a.jpg", 5)
+	r := p.WrapReader(bytes.NewReader([]byte("hello")))
+	buf := make([]byte, 5)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	p.FileDone()
+	p.Finish()
+}
+
+func TestProgressReporterNilIsNoOp(t *testing.T) {
+	var p *ProgressReporter
+
+	p.FileStarted("a.jpg", 5)
+	r := p.WrapReader(bytes.NewReader([]byte("hello")))
+	if r == nil {
+		t.Error("expected WrapReader to return the original reader unchanged")
+	}
+
+	p.FileDone()
+	p.Finish()
+}
+
+func TestProgressReporterZeroFilesIsNoOp(t *testing.T) {
+	p := NewProgressReporter(0, 0, true)
+	if p.enabled {
+		t.Error("expected reporter with zero files to stay disabled")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// S3Backend is a PhotoBackend that stores each album under its own
+// bucket-relative prefix (bucket/prefix/AlbumName/file), with a JSON
+// metadata sidecar object alongside every upload, the same way
+// LocalMirrorBackend does on disk.
+type S3Backend struct {
+	httpClient *http.Client
+	signer     *awsSigner
+	bucket     string
+	endpoint   string // e.g. https://<bucket>.s3.<region>.amazonaws.com, overridable for S3-compatible stores
+	prefix     string
+
+	// currentAlbum mirrors LocalMirrorBackend: ProcessFolder only has one
+	// album open for upload at a time, even with --parallel workers.
+	currentAlbum string
+}
+
+func NewS3Backend(cfg BackendConfig) *S3Backend {
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", cfg.S3Bucket, cfg.S3Region)
+	}
+	return &S3Backend{
+		httpClient: http.DefaultClient,
+		signer:     &awsSigner{accessKey: cfg.S3AccessKey, secretKey: cfg.S3SecretKey, region: cfg.S3Region},
+		bucket:     cfg.S3Bucket,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		prefix:     strings.Trim(cfg.S3Prefix, "/"),
+	}
+}
+
+func (b *S3Backend) key(albumPrefix, name string) string {
+	if albumPrefix == "" {
+		return name
+	}
+	return albumPrefix + "/" + name
+}
+
+func (b *S3Backend) metaKey(key string) string {
+	return key + ".metajson"
+}
+
+func (b *S3Backend) albumPrefix(title string) string {
+	if b.prefix == "" {
+		return title
+	}
+	return b.prefix + "/" + title
+}
+
+func (b *S3Backend) GetOrCreateAlbum(title string, existingAlbums map[string]string) (string, error) {
+	if id, ok := existingAlbums[title]; ok {
+		b.currentAlbum = id
+		return id, nil
+	}
+	return b.CreateAlbum(title)
+}
+
+// CreateAlbum doesn't need to do anything remotely - S3 has no real
+// directories, so the "album" is just the prefix objects get written
+// under.
+func (b *S3Backend) CreateAlbum(title string) (string, error) {
+	b.currentAlbum = b.albumPrefix(title)
+	return b.currentAlbum, nil
+}
+
+func (b *S3Backend) putObject(key string, body []byte, contentType string) error {
+	req, err := http.NewRequest("PUT", b.endpoint+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	b.signer.sign(req, hashHex(string(body)))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("put %s failed: %d %s", key, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (b *S3Backend) getObject(key string) ([]byte, error) {
+	req, err := http.NewRequest("GET", b.endpoint+"/"+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.signer.sign(req, hashHex(""))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s failed: %d", key, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (b *S3Backend) deleteObject(key string) error {
+	req, err := http.NewRequest("DELETE", b.endpoint+"/"+key, nil)
+	if err != nil {
+		return err
+	}
+	b.signer.sign(req, hashHex(""))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("delete %s failed: %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// UploadFile PUTs fpath's contents to <currentAlbum>/<name> and a
+// LocalMediaMetadata sidecar to the same key plus ".metajson". The object
+// key is returned as the upload token.
+func (b *S3Backend) UploadFile(fpath string, filenameOverride string) (string, error) {
+	if b.currentAlbum == "" {
+		return "", fmt.Errorf("no album open: call GetOrCreateAlbum first")
+	}
+
+	name := filenameOverride
+	if name == "" {
+		name = path.Base(fpath)
+	}
+	key := b.key(b.currentAlbum, name)
+
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", fmt.Errorf("read source file: %w", err)
+	}
+	if err := b.putObject(key, data, "application/octet-stream"); err != nil {
+		return "", err
+	}
+
+	info := GetLocalFileInfo(fpath)
+	meta := LocalMediaMetadata{
+		Filename:     name,
+		CreationTime: info.Date.Format("2006-01-02T15:04:05Z07:00"),
+		Width:        info.Width,
+		Height:       info.Height,
+		Size:         info.Size,
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal metadata: %w", err)
+	}
+	if err := b.putObject(b.metaKey(key), metaData, "application/json"); err != nil {
+		return "", fmt.Errorf("upload metadata: %w", err)
+	}
+
+	return key, nil
+}
+
+// AddToAlbum is a no-op confirmation step: UploadFile already wrote the
+// object under the album prefix, so every token succeeds.
+func (b *S3Backend) AddToAlbum(uploadTokens []string, albumID string) (map[int]bool, error) {
+	success := make(map[int]bool, len(uploadTokens))
+	for i := range uploadTokens {
+		success[i] = true
+	}
+	return success, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+func (b *S3Backend) ListAlbumItems(albumID string) (map[string]RemoteItemInfo, error) {
+	req, err := http.NewRequest("GET", b.endpoint+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	// Built by hand with awsQueryEscape rather than url.Values.Encode(),
+	// which form-encodes a space as "+" instead of the "%20" SigV4's
+	// canonical query string requires - signer.sign below signs
+	// req.URL.RawQuery verbatim, so that mismatch would otherwise produce a
+	// signature real S3 rejects for any album name containing a space.
+	req.URL.RawQuery = "list-type=2&prefix=" + awsQueryEscape(albumID+"/")
+	b.signer.sign(req, hashHex(""))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", albumID, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list %s failed: %d %s", albumID, resp.StatusCode, string(respBody))
+	}
+
+	var result listBucketResult
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("parse list response: %w", err)
+	}
+
+	items := make(map[string]RemoteItemInfo)
+	for _, c := range result.Contents {
+		if strings.HasSuffix(c.Key, ".metajson") {
+			continue
+		}
+		name := path.Base(c.Key)
+		info := RemoteItemInfo{ID: c.Key}
+		if metaData, err := b.getObject(b.metaKey(c.Key)); err == nil {
+			var meta LocalMediaMetadata
+			if json.Unmarshal(metaData, &meta) == nil {
+				info.CreationTime = meta.CreationTime
+				info.Width = strconv.Itoa(meta.Width)
+				info.Height = strconv.Itoa(meta.Height)
+			}
+		}
+		items[name] = info
+	}
+	return items, nil
+}
+
+// RemoveFromAlbum deletes mediaItemIDs (object keys returned by
+// ListAlbumItems) and their metadata sidecars.
+func (b *S3Backend) RemoveFromAlbum(albumID string, mediaItemIDs []string) error {
+	for _, key := range mediaItemIDs {
+		if err := b.deleteObject(key); err != nil {
+			return err
+		}
+		b.deleteObject(b.metaKey(key))
+	}
+	return nil
+}
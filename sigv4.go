@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// awsSigner signs S3 requests with AWS Signature Version 4, hand-rolled the
+// same way this project talks to the Google Photos and upload-resumable
+// APIs directly over net/http rather than pulling in a full vendor SDK for
+// a handful of calls.
+type awsSigner struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// sign adds the X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers
+// required for an S3 request. payloadHash is the hex-encoded SHA-256 of the
+// request body, or "UNSIGNED-PAYLOAD" to skip hashing a streamed upload.
+func (s *awsSigner) sign(req *http.Request, payloadHash string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.Host, payloadHash, amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, s.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex(canonicalRequest),
+	}, "\n")
+
+	kDate := hmacSHA256([]byte("AWS4"+s.secretKey), dateStamp)
+	kRegion := hmacSHA256(kDate, s.region)
+	kService := hmacSHA256(kRegion, "s3")
+	kSigning := hmacSHA256(kService, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(kSigning, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// awsQueryEscape percent-encodes s per SigV4's canonical query string rules:
+// RFC 3986 unreserved characters (A-Z a-z 0-9 - _ . ~) pass through
+// verbatim, everything else - including a space - is percent-encoded as
+// %XX. url.Values.Encode() instead form-encodes a space as "+", which
+// AWS's canonicalization doesn't accept; signing that query string produces
+// a signature real S3 rejects with SignatureDoesNotMatch for any value
+// (e.g. an album name) containing a space.
+func awsQueryEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// UploadDB is the bbolt database file written to each export directory,
+// replacing the old JSON upload log.
+const UploadDB = ".photo_pipe.db"
+
+var (
+	bucketUploaded  = []byte("uploaded")
+	bucketAlbums    = []byte("albums")
+	bucketResumable = []byte("resumable")
+)
+
+// UploadRecord is the value stored in the uploaded bucket, keyed by the
+// sha256 of the file's bytes so a moved or renamed file with identical bytes
+// is still recognized even though its path changed. A re-encoded copy has
+// different bytes and so a different key.
+type UploadRecord struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	MTime       time.Time `json:"mtime"`
+	MediaItemID string    `json:"mediaItemId"`
+	AlbumID     string    `json:"albumId"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// UploadStore is a bbolt-backed replacement for the old JSON upload log.
+// Unlike the JSON log, which was rewritten whole on every save, writes here
+// are transactional and incremental. pathIndex mirrors the uploaded bucket
+// in memory so IsUploaded can take a size+mtime fast path without hashing
+// every unchanged file on every run.
+type UploadStore struct {
+	db        *bbolt.DB
+	pathIndex map[string]UploadRecord
+}
+
+// OpenUploadStore opens (creating if necessary) the bbolt store in
+// exportDir, migrating a legacy JSON upload log on first run.
+func OpenUploadStore(exportDir string) (*UploadStore, error) {
+	dbPath := filepath.Join(exportDir, UploadDB)
+	db, err := bbolt.Open(dbPath, 0644, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open upload store: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{bucketUploaded, bucketAlbums, bucketResumable} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init upload store: %w", err)
+	}
+
+	s := &UploadStore{db: db, pathIndex: make(map[string]UploadRecord)}
+	if err := s.loadIndex(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.migrateJSONLog(exportDir); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *UploadStore) loadIndex() error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketUploaded).ForEach(func(k, v []byte) error {
+			var rec UploadRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			s.pathIndex[rec.Path] = rec
+			return nil
+		})
+	})
+}
+
+// migrateJSONLog imports a legacy JSON upload log on first run, hashing
+// each file it can still find on disk, then renames the old log to .bak so
+// it isn't imported again on the next run.
+func (s *UploadStore) migrateJSONLog(exportDir string) error {
+	logPath := filepath.Join(exportDir, UploadLog)
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var legacy uploadLogData
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("parse legacy upload log: %w", err)
+	}
+
+	for _, path := range legacy.Uploaded {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // file is gone; nothing left to key a hash off of
+		}
+		hash, err := HashFile(path)
+		if err != nil {
+			continue
+		}
+		if err := s.MarkUploaded(path, info.Size(), info.ModTime(), hash, "", ""); err != nil {
+			return err
+		}
+	}
+	for title, id := range legacy.Albums {
+		if err := s.SetAlbum(title, id); err != nil {
+			return err
+		}
+	}
+	for key, state := range legacy.Resumable {
+		if err := s.SetResumable(key, state); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(logPath, logPath+".bak")
+}
+
+// HashFile computes the sha256 of a file's contents.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// IsUploaded reports whether path has already been uploaded. It takes the
+// fast path (no hashing) when a prior record for this exact path already
+// has a matching size and mtime, and only falls back to hashing the file
+// when that's not the case, so a moved or renamed duplicate is still caught.
+func (s *UploadStore) IsUploaded(path string, size int64, mtime time.Time) (bool, error) {
+	if rec, ok := s.pathIndex[path]; ok && rec.Size == size && rec.MTime.Equal(mtime) {
+		return true, nil
+	}
+
+	hash, err := HashFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket(bucketUploaded).Get([]byte(hash)) != nil
+		return nil
+	})
+	return found, err
+}
+
+// MarkUploaded records path as uploaded, keyed by the sha256 of its bytes.
+// mediaItemID is best-effort: only Google Photos allocates a mediaItemID
+// distinct from the upload token (see MediaItemIDs), so it's simply empty
+// for backends that don't. albumID is whatever id the backend gave the album.
+func (s *UploadStore) MarkUploaded(path string, size int64, mtime time.Time, hash, mediaItemID, albumID string) error {
+	rec := UploadRecord{
+		Path:        path,
+		Size:        size,
+		MTime:       mtime,
+		MediaItemID: mediaItemID,
+		AlbumID:     albumID,
+		UploadedAt:  time.Now(),
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketUploaded).Put([]byte(hash), data)
+	}); err != nil {
+		return err
+	}
+
+	s.pathIndex[path] = rec
+	return nil
+}
+
+// UploadedCount returns the number of files recorded as uploaded.
+func (s *UploadStore) UploadedCount() int {
+	return len(s.pathIndex)
+}
+
+// Albums returns the cached album title -> id map.
+func (s *UploadStore) Albums() (map[string]string, error) {
+	albums := make(map[string]string)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketAlbums).ForEach(func(k, v []byte) error {
+			albums[string(k)] = string(v)
+			return nil
+		})
+	})
+	return albums, err
+}
+
+// SetAlbum caches the id the backend assigned to an album title.
+func (s *UploadStore) SetAlbum(title, id string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketAlbums).Put([]byte(title), []byte(id))
+	})
+}
+
+// Resumable returns all in-flight resumable upload sessions, dropping any
+// Google would already have expired on its side.
+func (s *UploadStore) Resumable() (map[string]ResumableState, error) {
+	states := make(map[string]ResumableState)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketResumable).ForEach(func(k, v []byte) error {
+			var state ResumableState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return err
+			}
+			if time.Now().After(state.ExpiresAt) {
+				return nil
+			}
+			states[string(k)] = state
+			return nil
+		})
+	})
+	return states, err
+}
+
+// SetResumable persists a resumable upload session so a crashed run can
+// pick the transfer back up instead of restarting from byte zero.
+func (s *UploadStore) SetResumable(key string, state ResumableState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketResumable).Put([]byte(key), data)
+	})
+}
+
+// DeleteResumable removes a completed or abandoned resumable session.
+func (s *UploadStore) DeleteResumable(key string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketResumable).Delete([]byte(key))
+	})
+}
+
+// SyncResumable replaces the resumable bucket's contents with states,
+// dropping any sessions that have since completed or expired.
+func (s *UploadStore) SyncResumable(states map[string]ResumableState) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(bucketResumable); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketResumable)
+		if err != nil {
+			return err
+		}
+		for key, state := range states {
+			data, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close releases the underlying bbolt database file.
+func (s *UploadStore) Close() error {
+	return s.db.Close()
+}
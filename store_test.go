@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenUploadStoreFresh(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if store.UploadedCount() != 0 {
+		t.Errorf("expected 0 uploaded, got %d", store.UploadedCount())
+	}
+	if _, err := os.Stat(filepath.Join(dir, UploadDB)); err != nil {
+		t.Errorf("expected db file to be created: %v", err)
+	}
+}
+
+func TestUploadStoreMarkAndIsUploaded(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "a.jpg")
+	os.WriteFile(fpath, []byte("photo data"), 0644)
+	info, _ := os.Stat(fpath)
+
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	uploaded, err := store.IsUploaded(fpath, info.Size(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded {
+		t.Error("expected file not to be marked uploaded yet")
+	}
+
+	hash, err := HashFile(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkUploaded(fpath, info.Size(), info.ModTime(), hash, "media123", "album1"); err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded, err = store.IsUploaded(fpath, info.Size(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("expected file to be marked uploaded")
+	}
+	if store.UploadedCount() != 1 {
+		t.Errorf("expected 1 uploaded, got %d", store.UploadedCount())
+	}
+	if rec := store.pathIndex[fpath]; rec.MediaItemID != "media123" || rec.AlbumID != "album1" {
+		t.Errorf("expected mediaItemID/albumID to be recorded, got %+v", rec)
+	}
+}
+
+func TestUploadStoreDetectsMovedFile(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "a.jpg")
+	os.WriteFile(original, []byte("same bytes"), 0644)
+	info, _ := os.Stat(original)
+
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	hash, _ := HashFile(original)
+	if err := store.MarkUploaded(original, info.Size(), info.ModTime(), hash, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := filepath.Join(dir, "renamed.jpg")
+	os.Rename(original, moved)
+	movedInfo, _ := os.Stat(moved)
+
+	uploaded, err := store.IsUploaded(moved, movedInfo.Size(), movedInfo.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("expected renamed file with identical bytes to be recognized by hash")
+	}
+}
+
+func TestUploadStoreAlbums(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.SetAlbum("Vacation", "album-id-1"); err != nil {
+		t.Fatal(err)
+	}
+	albums, err := store.Albums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if albums["Vacation"] != "album-id-1" {
+		t.Errorf("expected album id to round-trip, got %v", albums)
+	}
+}
+
+func TestUploadStoreResumable(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	state := ResumableState{
+		UploadURL: "https://example.com/upload",
+		Offset:    1024,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	key := ResumableKey("/a/b.mov", 2048, time.Unix(1000, 0))
+	if err := store.SetResumable(key, state); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := store.Resumable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states[key].UploadURL != state.UploadURL {
+		t.Errorf("expected resumable state to round-trip, got %v", states)
+	}
+}
+
+func TestUploadStoreResumableDropsExpired(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	key := ResumableKey("/a/b.mov", 2048, time.Unix(1000, 0))
+	expired := ResumableState{UploadURL: "stale", ExpiresAt: time.Now().Add(-time.Hour)}
+	if err := store.SetResumable(key, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := store.Resumable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := states[key]; exists {
+		t.Error("expected expired resumable session to be dropped")
+	}
+}
+
+func TestUploadStoreSyncResumableReplaces(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	keyA := ResumableKey("/a.mov", 1, time.Unix(1, 0))
+	keyB := ResumableKey("/b.mov", 2, time.Unix(2, 0))
+	if err := store.SetResumable(keyA, ResumableState{ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SyncResumable(map[string]ResumableState{
+		keyB: {ExpiresAt: time.Now().Add(time.Hour)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := store.Resumable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := states[keyA]; exists {
+		t.Error("expected keyA to be dropped after sync")
+	}
+	if _, exists := states[keyB]; !exists {
+		t.Error("expected keyB to be present after sync")
+	}
+}
+
+func TestOpenUploadStoreMigratesLegacyJSONLog(t *testing.T) {
+	dir := t.TempDir()
+	fpath := filepath.Join(dir, "a.jpg")
+	os.WriteFile(fpath, []byte("legacy photo"), 0644)
+
+	legacy := `{
+  "uploaded": ["` + fpath + `"],
+  "albums": {"Legacy": "legacy-id"}
+}`
+	os.WriteFile(filepath.Join(dir, UploadLog), []byte(legacy), 0644)
+
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if store.UploadedCount() != 1 {
+		t.Errorf("expected 1 migrated upload, got %d", store.UploadedCount())
+	}
+	info, _ := os.Stat(fpath)
+	uploaded, err := store.IsUploaded(fpath, info.Size(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("expected migrated file to be recognized as uploaded")
+	}
+
+	albums, err := store.Albums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if albums["Legacy"] != "legacy-id" {
+		t.Errorf("expected migrated album, got %v", albums)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, UploadLog)); !os.IsNotExist(err) {
+		t.Error("expected legacy log to be renamed away")
+	}
+	if _, err := os.Stat(filepath.Join(dir, UploadLog+".bak")); err != nil {
+		t.Errorf("expected legacy log to be renamed to .bak: %v", err)
+	}
+}
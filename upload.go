@@ -2,35 +2,52 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+	"sort"
+	"sync"
 )
 
+// DefaultParallelUploads is how many files ProcessFolder uploads at once
+// when the caller doesn't override it with --parallel.
+const DefaultParallelUploads = 4
+
 type UploadResult struct {
-	Added   int
-	Skipped int
+	Added    int
+	Skipped  int
+	Filtered int
 }
 
 func ProcessFolder(
-	client *GooglePhotosClient,
+	client PhotoBackend,
 	folder string,
 	existingAlbums map[string]string,
-	uploadedLog map[string]bool,
+	store *UploadStore,
 	skipExisting bool,
 	dryRun bool,
 	canReadLibrary bool,
 	stdinReader *bufio.Reader,
+	exifBatcher *ExifBatcher,
+	filter *Filter,
+	parallel int,
+	quiet bool,
+	ctx context.Context,
+	patterns []AlbumPattern,
+	exportTakeout bool,
 ) (UploadResult, error) {
-	albumName := filepath.Base(folder)
-	files, err := FindMediaFiles(folder)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sourceAlbum := filepath.Base(folder)
+	files, filteredCount, err := FindMediaFiles(folder, filter)
 	if err != nil {
 		return UploadResult{}, err
 	}
 
 	if len(files) == 0 {
-		return UploadResult{}, nil
+		return UploadResult{Filtered: filteredCount}, nil
 	}
 
 	// Calculate total size
@@ -43,29 +60,46 @@ func ProcessFolder(
 
 	// Filter already uploaded
 	skipped := 0
-	if skipExisting {
+	if skipExisting && store != nil {
 		var filtered []string
 		for _, f := range files {
 			absPath, _ := filepath.Abs(f)
-			if uploadedLog[absPath] {
-				skipped++
-			} else {
-				filtered = append(filtered, f)
+			info, err := os.Stat(f)
+			if err == nil {
+				if uploaded, err := store.IsUploaded(absPath, info.Size(), info.ModTime()); err == nil && uploaded {
+					skipped++
+					continue
+				}
 			}
+			filtered = append(filtered, f)
 		}
 		files = filtered
 	}
 
+	// Enrich with EXIF dates (when exiftool is available) and sort
+	// chronologically so the album reads in capture order.
+	fileInfos := make(map[string]LocalFileInfo, len(files))
+	for _, f := range files {
+		info := GetLocalFileInfo(f)
+		EnrichWithExif(&info, f, exifBatcher)
+		fileInfos[f] = info
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return fileInfos[files[i]].Date.Before(fileInfos[files[j]].Date)
+	})
+
 	// Print folder header
-	fmt.Printf("\n  📁 %s\n", albumName)
-	skippedStr := ""
-	if skipped > 0 {
-		skippedStr = fmt.Sprintf(" (пропущено %d ранее загруженных)", skipped)
+	if !quiet {
+		fmt.Printf("\n  📁 %s\n", sourceAlbum)
+		skippedStr := ""
+		if skipped > 0 {
+			skippedStr = fmt.Sprintf(" (пропущено %d ранее загруженных)", skipped)
+		}
+		fmt.Printf("     %d файлов (%s)%s\n", len(files), FormatSize(totalSize), skippedStr)
 	}
-	fmt.Printf("     %d файлов (%s)%s\n", len(files), FormatSize(totalSize), skippedStr)
 
 	if len(files) == 0 {
-		return UploadResult{Skipped: skipped}, nil
+		return UploadResult{Skipped: skipped, Filtered: filteredCount}, nil
 	}
 
 	// Dry run mode
@@ -78,13 +112,81 @@ func ProcessFolder(
 			}
 			fmt.Printf("     → %s (%s)\n", filepath.Base(f), FormatSize(size))
 		}
-		return UploadResult{Skipped: skipped}, nil
+		return UploadResult{Skipped: skipped, Filtered: filteredCount}, nil
 	}
 
+	// Bucket files into destination albums. With no patterns, this is just
+	// the folder's own name (the pre-chunk1-6 behavior); with patterns, one
+	// file can land in several album "views" of the same source tree (e.g.
+	// both by-year/2024 and favorites), so each group is processed like its
+	// own independent ProcessFolder call sharing the already-sorted files.
+	groups := bucketByAlbum(files, fileInfos, sourceAlbum, patterns)
+
+	result := UploadResult{Skipped: skipped, Filtered: filteredCount}
+	for _, name := range sortedAlbumNames(groups) {
+		added, err := uploadToAlbum(ctx, client, name, groups[name], fileInfos, existingAlbums, store, canReadLibrary, stdinReader, exifBatcher, parallel, quiet, exportTakeout, &result.Skipped)
+		result.Added += added
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// bucketByAlbum groups files by destination album name. With no patterns,
+// every file goes to the single group named sourceAlbum (the folder's own
+// name), preserving ProcessFolder's pre-pattern behavior exactly.
+func bucketByAlbum(files []string, fileInfos map[string]LocalFileInfo, sourceAlbum string, patterns []AlbumPattern) map[string][]string {
+	groups := make(map[string][]string)
+	if len(patterns) == 0 {
+		groups[sourceAlbum] = files
+		return groups
+	}
+	for _, f := range files {
+		for _, album := range ResolveAlbumsForFile(patterns, fileInfos[f], sourceAlbum) {
+			groups[album] = append(groups[album], f)
+		}
+	}
+	return groups
+}
+
+func sortedAlbumNames(groups map[string][]string) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// uploadToAlbum gets or creates albumName, resolves duplicates against it,
+// and uploads files into it through a worker pool, recording successes in
+// store. It's the per-album body ProcessFolder used to run once per folder;
+// with album patterns it now runs once per resolved album "view" instead.
+// skippedOut accumulates duplicate/rename skips across every group in the
+// same ProcessFolder call (skipping in one view shouldn't reduce Added for
+// another, but should still count toward the overall skipped total).
+func uploadToAlbum(
+	ctx context.Context,
+	client PhotoBackend,
+	albumName string,
+	files []string,
+	fileInfos map[string]LocalFileInfo,
+	existingAlbums map[string]string,
+	store *UploadStore,
+	canReadLibrary bool,
+	stdinReader *bufio.Reader,
+	exifBatcher *ExifBatcher,
+	parallel int,
+	quiet bool,
+	exportTakeout bool,
+	skippedOut *int,
+) (int, error) {
 	// Get or create album
 	albumID, err := client.GetOrCreateAlbum(albumName, existingAlbums)
 	if err != nil {
-		return UploadResult{Skipped: skipped}, fmt.Errorf("album error: %w", err)
+		return 0, fmt.Errorf("album error: %w", err)
 	}
 
 	// Check remote items for duplicates
@@ -92,33 +194,58 @@ func ProcessFolder(
 	if canReadLibrary {
 		remoteItems, err = client.ListAlbumItems(albumID)
 		if err != nil {
-			return UploadResult{Skipped: skipped}, fmt.Errorf("list items error: %w", err)
+			return 0, fmt.Errorf("list items error: %w", err)
 		}
-		if len(remoteItems) > 0 {
-			fmt.Printf("     📋 В альбоме уже %d файлов\n", len(remoteItems))
+		if len(remoteItems) > 0 && !quiet {
+			fmt.Printf("     📋 %s: уже %d файлов\n", albumName, len(remoteItems))
 		}
 	}
 
 	type uploadEntry struct {
 		token   string
 		absPath string
+		fpath   string
 	}
 
-	var uploads []uploadEntry
+	// Resolve each file's upload name first, sequentially, since duplicate
+	// handling prompts the user on stdin and mutates albumID state (via
+	// RemoveFromAlbum) - that can't be parallelized. The resulting jobs
+	// slice keeps the chronological order files were sorted into above.
+	type uploadJob struct {
+		pos         int // original position in files, used only for display
+		slot        int // position in the jobs slice, used to index results
+		fpath       string
+		displayName string
+		uploadName  string
+	}
+
+	var jobs []uploadJob
 	total := len(files)
 
 	for idx, fpath := range files {
 		displayName := filepath.Base(fpath)
-		realPath := fpath
 		uploadName := ""
 
 		// Check for remote duplicates
 		if remoteItems != nil {
 			if remote, exists := remoteItems[displayName]; exists {
-				choice := PromptDuplicate(fpath, remote, stdinReader)
+				if RemoteCreationTimeMatches(remote.CreationTime, fileInfos[fpath].Date) {
+					*skippedOut++
+					continue
+				}
+				similarity := -1.0
+				if hasher, ok := client.(ThumbnailHasher); ok && remote.BaseURL != "" && IsHashableImage(fpath) {
+					if localHash, err := DHashFile(fpath); err == nil {
+						if remoteHash, err := hasher.FetchThumbnailHash(remote.BaseURL); err == nil {
+							similarity = SimilarityPercent(localHash, remoteHash)
+						}
+					}
+				}
+
+				choice := PromptDuplicate(fpath, remote, similarity, stdinReader)
 				switch choice {
 				case "s":
-					skipped++
+					*skippedOut++
 					continue
 				case "r":
 					if err := client.RemoveFromAlbum(albumID, []string{remote.ID}); err != nil {
@@ -131,37 +258,102 @@ func ProcessFolder(
 					line, _ := stdinReader.ReadString('\n')
 					newName := trimNewline(line)
 					if newName == "" {
-						skipped++
+						*skippedOut++
 						continue
 					}
 					uploadName = newName
 					displayName = newName
+				case "k":
+					uploadName = KeepBothName(displayName, fpath)
+					displayName = uploadName
 				}
 			}
 		}
 
-		fmt.Printf("     ⬆️  [%d/%d] %s", idx+1, total, displayName)
-
-		token, err := client.UploadFile(realPath, uploadName)
-		if err != nil {
-			fmt.Println(" ✗")
-			fmt.Printf("  %v\n", err)
-			continue
+		// When exiftool is available, give each file a canonical date-prefixed
+		// upload name so Google Photos groups them correctly even without user
+		// renaming. The date is fileInfos' date: the EXIF date when extraction
+		// succeeded, otherwise GetLocalFileInfo's own date.
+		if uploadName == "" && exifBatcher != nil {
+			if canonical := CanonicalUploadName(fileInfos[fpath]); canonical != displayName {
+				uploadName = canonical
+			}
 		}
-		fmt.Println(" ✓")
 
-		absPath, _ := filepath.Abs(realPath)
-		uploads = append(uploads, uploadEntry{token: token, absPath: absPath})
+		jobs = append(jobs, uploadJob{pos: idx, slot: len(jobs), fpath: fpath, displayName: displayName, uploadName: uploadName})
+	}
+
+	// Fan out the actual uploads across a worker pool - this is the part
+	// that's latency-bound on round trips and benefits from concurrency.
+	// The pacer on client is shared across all workers, so the adaptive
+	// rate limit still applies to the pool as a whole, not per worker.
+	workers := parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	results := make([]uploadEntry, len(jobs))
+	jobCh := make(chan uploadJob)
+	var printMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				token, err := client.UploadFile(job.fpath, job.uploadName)
 
-		// Rate limiting: 2s sleep every 20 files
-		if (idx+1)%20 == 0 && idx+1 < total {
-			time.Sleep(2 * time.Second)
+				if !quiet {
+					printMu.Lock()
+					if err != nil {
+						fmt.Printf("     ⬆️  [%d/%d] %s ✗\n  %v\n", job.pos+1, total, job.displayName, err)
+					} else {
+						fmt.Printf("     ⬆️  [%d/%d] %s ✓\n", job.pos+1, total, job.displayName)
+					}
+					printMu.Unlock()
+				}
+
+				if err != nil {
+					continue
+				}
+				absPath, _ := filepath.Abs(job.fpath)
+				// Each worker only ever writes its own job's slot, so this
+				// needs no lock despite results being shared.
+				results[job.slot] = uploadEntry{token: token, absPath: absPath, fpath: job.fpath}
+			}
+		}()
+	}
+
+	// Stop dispatching new jobs once ctx is cancelled (e.g. SIGINT), but let
+	// whatever's already in flight finish rather than aborting mid-request -
+	// an upload that's 90% through shouldn't be thrown away just because the
+	// user asked the run to stop starting new ones.
+	go func() {
+		defer close(jobCh)
+		for _, job := range jobs {
+			select {
+			case <-ctx.Done():
+				return
+			case jobCh <- job:
+			}
+		}
+	}()
+	wg.Wait()
+
+	var uploads []uploadEntry
+	for _, e := range results {
+		if e.token != "" {
+			uploads = append(uploads, e)
 		}
 	}
 
 	if len(uploads) == 0 {
 		fmt.Println("     ⚠️  Ни один файл не загружен")
-		return UploadResult{Skipped: skipped}, nil
+		return 0, nil
 	}
 
 	// Add to album
@@ -170,40 +362,94 @@ func ProcessFolder(
 		tokens[i] = u.token
 	}
 
-	fmt.Printf("     📎 Добавляю %d файлов в альбом...", len(tokens))
+	if !quiet {
+		fmt.Printf("     📎 Добавляю %d файлов в альбом %q...", len(tokens), albumName)
+	}
 
-	successIndices, err := client.AddToAlbum(tokens, albumID)
+	// Attach each file's EXIF/Takeout description as the item's caption
+	// when the backend supports it (currently only Google Photos).
+	var descriptions []string
+	if _, ok := client.(DescriptionSetter); ok {
+		descriptions = make([]string, len(uploads))
+		for i, u := range uploads {
+			descriptions[i] = fileInfos[u.fpath].Description
+		}
+	}
+
+	addToAlbum := func(albumID string) (map[int]bool, error) {
+		if setter, ok := client.(DescriptionSetter); ok {
+			return setter.AddToAlbumWithDescriptions(tokens, albumID, descriptions)
+		}
+		return client.AddToAlbum(tokens, albumID)
+	}
+
+	successIndices, err := addToAlbum(albumID)
 
 	// If album ID is stale (404), create a new album and retry
 	if err != nil && len(successIndices) == 0 {
-		fmt.Println()
-		fmt.Printf("     🔄 Альбом не найден, создаю заново...")
+		if !quiet {
+			fmt.Println()
+			fmt.Printf("     🔄 Альбом не найден, создаю заново...")
+		}
 		delete(existingAlbums, albumName)
 		newID, createErr := client.CreateAlbum(albumName)
 		if createErr != nil {
-			fmt.Println(" ✗")
-			return UploadResult{Skipped: skipped}, fmt.Errorf("recreate album error: %w", createErr)
+			if !quiet {
+				fmt.Println(" ✗")
+			}
+			return 0, fmt.Errorf("recreate album error: %w", createErr)
 		}
 		existingAlbums[albumName] = newID
 		albumID = newID
-		fmt.Println(" ✓")
-		fmt.Printf("     📎 Добавляю %d файлов в альбом...", len(tokens))
-		successIndices, err = client.AddToAlbum(tokens, albumID)
+		if !quiet {
+			fmt.Println(" ✓")
+			fmt.Printf("     📎 Добавляю %d файлов в альбом %q...", len(tokens), albumName)
+		}
+		successIndices, err = addToAlbum(albumID)
 	}
 
 	if err != nil {
-		fmt.Println(" ✗")
-		return UploadResult{Skipped: skipped}, fmt.Errorf("add to album error: %w", err)
+		if !quiet {
+			fmt.Println(" ✗")
+		}
+		return 0, fmt.Errorf("add to album error: %w", err)
 	}
 
-	fmt.Printf(" ✓ (%d добавлено)\n", len(successIndices))
+	if !quiet {
+		fmt.Printf(" ✓ (%d добавлено)\n", len(successIndices))
+	}
 
-	// Update uploaded log with successfully added files
-	for idx := range successIndices {
-		uploadedLog[uploads[idx].absPath] = true
+	// Record successfully added files in the upload store, hashing each one
+	// so it's still recognized if it's later moved or renamed.
+	if store != nil {
+		for idx := range successIndices {
+			e := uploads[idx]
+			info, statErr := os.Stat(e.absPath)
+			if statErr != nil {
+				continue
+			}
+			hash, hashErr := HashFile(e.absPath)
+			if hashErr != nil {
+				continue
+			}
+			mediaItemID := ""
+			if lookup, ok := client.(MediaItemIDs); ok {
+				if id, found := lookup.MediaItemID(e.token); found {
+					mediaItemID = id
+				}
+			}
+			if err := store.MarkUploaded(e.absPath, info.Size(), info.ModTime(), hash, mediaItemID, albumID); err != nil {
+				fmt.Printf("  ⚠️  Не удалось сохранить запись о загрузке: %v\n", err)
+			}
+			if exportTakeout {
+				if err := WriteTakeoutSidecar(e.fpath, fileInfos[e.fpath]); err != nil {
+					fmt.Printf("  ⚠️  Не удалось записать Takeout-сайдкар: %v\n", err)
+				}
+			}
+		}
 	}
 
-	return UploadResult{Added: len(successIndices), Skipped: skipped}, nil
+	return len(successIndices), nil
 }
 
 func trimNewline(s string) string {
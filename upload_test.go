@@ -2,13 +2,18 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func setupUploadTestServer() *httptest.Server {
@@ -62,7 +67,7 @@ func TestProcessFolderDryRun(t *testing.T) {
 	os.WriteFile(filepath.Join(folder, "a.jpg"), []byte("photo"), 0644)
 	os.WriteFile(filepath.Join(folder, "b.png"), []byte("image"), 0644)
 
-	result, err := ProcessFolder(nil, folder, nil, nil, false, true, false, nil)
+	result, err := ProcessFolder(nil, folder, nil, nil, false, true, false, nil, nil, nil, 4, false, context.Background(), nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -76,7 +81,7 @@ func TestProcessFolderEmpty(t *testing.T) {
 	folder := filepath.Join(dir, "Empty")
 	os.Mkdir(folder, 0755)
 
-	result, err := ProcessFolder(nil, folder, nil, nil, false, true, false, nil)
+	result, err := ProcessFolder(nil, folder, nil, nil, false, true, false, nil, nil, nil, 4, false, context.Background(), nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,6 +97,7 @@ func TestProcessFolderUpload(t *testing.T) {
 	client := &GooglePhotosClient{
 		httpClient: server.Client(),
 		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
 	}
 
 	dir := t.TempDir()
@@ -100,10 +106,14 @@ func TestProcessFolderUpload(t *testing.T) {
 	os.WriteFile(filepath.Join(folder, "a.jpg"), []byte("photo data"), 0644)
 
 	existingAlbums := make(map[string]string)
-	uploadedLog := make(map[string]bool)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
 	reader := bufio.NewReader(os.Stdin)
 
-	result, err := ProcessFolder(client, folder, existingAlbums, uploadedLog, false, false, false, reader)
+	result, err := ProcessFolder(client, folder, existingAlbums, store, false, false, false, reader, nil, nil, 4, false, context.Background(), nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,10 +121,16 @@ func TestProcessFolderUpload(t *testing.T) {
 		t.Errorf("expected 1 added, got %d", result.Added)
 	}
 
-	// Check uploaded log was updated
-	absPath, _ := filepath.Abs(filepath.Join(folder, "a.jpg"))
-	if !uploadedLog[absPath] {
-		t.Error("expected file to be in upload log")
+	// Check the upload store was updated
+	fpath := filepath.Join(folder, "a.jpg")
+	absPath, _ := filepath.Abs(fpath)
+	info, _ := os.Stat(fpath)
+	uploaded, err := store.IsUploaded(absPath, info.Size(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("expected file to be recorded in the upload store")
 	}
 }
 
@@ -126,9 +142,19 @@ func TestProcessFolderSkipExisting(t *testing.T) {
 	os.WriteFile(fpath, []byte("photo"), 0644)
 
 	absPath, _ := filepath.Abs(fpath)
-	uploadedLog := map[string]bool{absPath: true}
+	info, _ := os.Stat(fpath)
 
-	result, err := ProcessFolder(nil, folder, nil, uploadedLog, true, true, false, nil)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	hash, _ := HashFile(fpath)
+	if err := store.MarkUploaded(absPath, info.Size(), info.ModTime(), hash, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessFolder(nil, folder, nil, store, true, true, false, nil, nil, nil, 4, false, context.Background(), nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -144,6 +170,7 @@ func TestProcessFolderMultipleFiles(t *testing.T) {
 	client := &GooglePhotosClient{
 		httpClient: server.Client(),
 		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
 	}
 
 	dir := t.TempDir()
@@ -154,17 +181,436 @@ func TestProcessFolderMultipleFiles(t *testing.T) {
 	}
 
 	existingAlbums := make(map[string]string)
-	uploadedLog := make(map[string]bool)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
 	reader := bufio.NewReader(os.Stdin)
 
-	result, err := ProcessFolder(client, folder, existingAlbums, uploadedLog, false, false, false, reader)
+	result, err := ProcessFolder(client, folder, existingAlbums, store, false, false, false, reader, nil, nil, 4, false, context.Background(), nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if result.Added != 3 {
 		t.Errorf("expected 3 added, got %d", result.Added)
 	}
-	if len(uploadedLog) != 3 {
-		t.Errorf("expected 3 in upload log, got %d", len(uploadedLog))
+	if store.UploadedCount() != 3 {
+		t.Errorf("expected 3 in upload store, got %d", store.UploadedCount())
+	}
+}
+
+// TestProcessFolderConcurrentStress uploads 500 synthetic files through the
+// worker pool and verifies the pacer-shared, concurrent path doesn't drop
+// or duplicate any upload token on the way into AddToAlbum.
+func TestProcessFolderConcurrentStress(t *testing.T) {
+	const numFiles = 500
+
+	var tokenCounter int64
+	var mu sync.Mutex
+	seenTokens := make(map[string]int) // token -> times seen in batchCreate
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			json.NewEncoder(w).Encode(map[string]string{"id": "stress-album-id"})
+		} else {
+			json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+		}
+	})
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		n := atomic.AddInt64(&tokenCounter, 1)
+		w.Write([]byte(fmt.Sprintf("token-%d", n)))
+	})
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NewMediaItems []struct {
+				SimpleMediaItem struct {
+					UploadToken string `json:"uploadToken"`
+				} `json:"simpleMediaItem"`
+			} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		for _, item := range body.NewMediaItems {
+			seenTokens[item.SimpleMediaItem.UploadToken]++
+		}
+		mu.Unlock()
+
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i := range body.NewMediaItems {
+			results[i] = map[string]interface{}{"status": map[string]interface{}{"message": "Success"}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"newMediaItemResults": results})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &GooglePhotosClient{
+		httpClient: server.Client(),
+		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
+	}
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "Stress")
+	os.Mkdir(folder, 0755)
+	for i := 0; i < numFiles; i++ {
+		name := fmt.Sprintf("img-%04d.jpg", i)
+		os.WriteFile(filepath.Join(folder, name), []byte(fmt.Sprintf("data-%d", i)), 0644)
+	}
+
+	existingAlbums := make(map[string]string)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	reader := bufio.NewReader(os.Stdin)
+
+	result, err := ProcessFolder(client, folder, existingAlbums, store, false, false, false, reader, nil, nil, 16, false, context.Background(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != numFiles {
+		t.Errorf("expected %d added, got %d", numFiles, result.Added)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seenTokens) != numFiles {
+		t.Errorf("expected %d unique tokens reaching AddToAlbum, got %d", numFiles, len(seenTokens))
+	}
+	for token, count := range seenTokens {
+		if count != 1 {
+			t.Errorf("token %s seen %d times, expected exactly once", token, count)
+		}
+	}
+}
+
+// TestProcessFolderStopsDispatchOnCancelledContext passes an already-cancelled
+// context and verifies ProcessFolder stops handing out new upload jobs
+// rather than uploading every file, since the worker-pool dispatch loop
+// selects on ctx.Done() before sending each job.
+func TestProcessFolderStopsDispatchOnCancelledContext(t *testing.T) {
+	var uploadCount int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			json.NewEncoder(w).Encode(map[string]string{"id": "cancel-album-id"})
+		} else {
+			json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+		}
+	})
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		atomic.AddInt64(&uploadCount, 1)
+		w.Write([]byte("upload-token"))
+	})
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NewMediaItems []interface{} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i := range body.NewMediaItems {
+			results[i] = map[string]interface{}{"status": map[string]interface{}{"message": "Success"}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"newMediaItemResults": results})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &GooglePhotosClient{
+		httpClient: server.Client(),
+		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
+	}
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "Cancelled")
+	os.Mkdir(folder, 0755)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("img-%02d.jpg", i)
+		os.WriteFile(filepath.Join(folder, name), []byte(fmt.Sprintf("data-%d", i)), 0644)
+	}
+
+	existingAlbums := make(map[string]string)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	reader := bufio.NewReader(os.Stdin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := ProcessFolder(client, folder, existingAlbums, store, false, false, false, reader, nil, nil, 4, false, ctx, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added == 20 {
+		t.Errorf("expected cancellation to stop dispatch before all 20 files uploaded, got all 20 added")
+	}
+}
+
+// TestProcessFolderPreservesOrderUnderThrottling throttles every third
+// upload with a 429 and uploads concurrently, then verifies the files
+// still land in the album in chronological (filename) order regardless of
+// which worker's retry happened to finish last.
+func TestProcessFolderPreservesOrderUnderThrottling(t *testing.T) {
+	var uploadCalls int32
+	var gotTokens []string
+	var mu sync.Mutex
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			json.NewEncoder(w).Encode(map[string]string{"id": "order-album-id"})
+		} else {
+			json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+		}
+	})
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&uploadCalls, 1)%3 == 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("token-" + string(body)))
+	})
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NewMediaItems []struct {
+				SimpleMediaItem struct {
+					UploadToken string `json:"uploadToken"`
+				} `json:"simpleMediaItem"`
+			} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		for _, item := range body.NewMediaItems {
+			gotTokens = append(gotTokens, item.SimpleMediaItem.UploadToken)
+		}
+		mu.Unlock()
+
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i := range body.NewMediaItems {
+			results[i] = map[string]interface{}{"status": map[string]interface{}{"message": "Success"}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"newMediaItemResults": results})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &GooglePhotosClient{
+		httpClient: server.Client(),
+		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
+	}
+	client.pacer.minInterval = time.Millisecond
+	client.pacer.interval = time.Millisecond
+	client.pacer.maxInterval = 5 * time.Millisecond
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "Ordered")
+	os.Mkdir(folder, 0755)
+	names := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg", "g.jpg", "h.jpg"}
+	for _, name := range names {
+		os.WriteFile(filepath.Join(folder, name), []byte(name), 0644)
+	}
+
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	reader := bufio.NewReader(os.Stdin)
+
+	result, err := ProcessFolder(client, folder, make(map[string]string), store, false, false, false, reader, nil, nil, 4, false, context.Background(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != len(names) {
+		t.Fatalf("expected %d added, got %d", len(names), result.Added)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(gotTokens) != len(names) {
+		t.Fatalf("expected %d tokens in batchCreate, got %d", len(names), len(gotTokens))
+	}
+	for i, name := range names {
+		want := "token-" + name
+		if gotTokens[i] != want {
+			t.Errorf("album order mismatch at position %d: got %q, want %q", i, gotTokens[i], want)
+		}
+	}
+}
+
+// TestProcessFolderAlbumPatternsBucketIntoMultipleAlbums uploads one
+// favorited and one plain file with both a default "{album}" pattern and a
+// "favorites[favorite:true]" pattern active, and checks the favorited file
+// lands in both the folder's own album and "favorites" while the plain file
+// only lands in the folder's album.
+func TestProcessFolderAlbumPatternsBucketIntoMultipleAlbums(t *testing.T) {
+	var mu sync.Mutex
+	albumMembers := make(map[string]map[string]bool) // album title -> set of uploaded tokens
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var body struct {
+				Album struct {
+					Title string `json:"title"`
+				} `json:"album"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]string{"id": "album:" + body.Album.Title})
+		} else {
+			json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+		}
+	})
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("token-" + string(body)))
+	})
+	mux.HandleFunc("/mediaItems:batchCreate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			AlbumID       string `json:"albumId"`
+			NewMediaItems []struct {
+				SimpleMediaItem struct {
+					UploadToken string `json:"uploadToken"`
+				} `json:"simpleMediaItem"`
+			} `json:"newMediaItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		if albumMembers[body.AlbumID] == nil {
+			albumMembers[body.AlbumID] = make(map[string]bool)
+		}
+		results := make([]map[string]interface{}, len(body.NewMediaItems))
+		for i, item := range body.NewMediaItems {
+			albumMembers[body.AlbumID][item.SimpleMediaItem.UploadToken] = true
+			results[i] = map[string]interface{}{"status": map[string]interface{}{"message": "Success"}}
+		}
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"newMediaItemResults": results})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &GooglePhotosClient{
+		httpClient: server.Client(),
+		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
+	}
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "Trip")
+	os.Mkdir(folder, 0755)
+	os.WriteFile(filepath.Join(folder, "fav.jpg"), []byte("fav data"), 0644)
+	os.WriteFile(filepath.Join(folder, "fav.jpg.json"), []byte(`{"favorited": {"value": true}}`), 0644)
+	os.WriteFile(filepath.Join(folder, "plain.jpg"), []byte("plain data"), 0644)
+
+	patterns, err := ParseAlbumPatterns([]string{"{album}", "favorites[favorite:true]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existingAlbums := make(map[string]string)
+	store, err := OpenUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	reader := bufio.NewReader(os.Stdin)
+
+	result, err := ProcessFolder(client, folder, existingAlbums, store, false, false, false, reader, nil, nil, 4, false, context.Background(), patterns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fav.jpg uploads into both "Trip" and "favorites"; plain.jpg uploads
+	// only into "Trip" - so batchCreate sees 3 successful additions total.
+	if result.Added != 3 {
+		t.Errorf("expected 3 successful additions across both albums, got %d", result.Added)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(albumMembers["album:Trip"]) != 2 {
+		t.Errorf("expected 2 files in the Trip album, got %d", len(albumMembers["album:Trip"]))
+	}
+	if len(albumMembers["album:favorites"]) != 1 {
+		t.Errorf("expected 1 file in the favorites album, got %d", len(albumMembers["album:favorites"]))
+	}
+}
+
+// TestProcessFolderAutoSkipsConfirmedDuplicate exercises the
+// filename+creationTime duplicate heuristic: a remote item whose filename
+// and creationTime both match the local file is skipped automatically
+// (Google Photos' API exposes no remote byte size to check against), so
+// this never reaches the interactive prompt and never calls /uploads.
+func TestProcessFolderAutoSkipsConfirmedDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "Album3")
+	os.Mkdir(folder, 0755)
+	fpath := filepath.Join(folder, "a.jpg")
+	os.WriteFile(fpath, []byte("photo"), 0644)
+
+	mtime := time.Date(2025, 2, 16, 20, 32, 22, 0, time.UTC)
+	os.Chtimes(fpath, mtime, mtime)
+
+	var uploadsCalled int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/albums", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"albums": []map[string]string{}})
+	})
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&uploadsCalled, 1)
+		io.ReadAll(r.Body)
+		w.Write([]byte("upload-token"))
+	})
+	mux.HandleFunc("/mediaItems:search", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mediaItems": []map[string]interface{}{
+				{
+					"id":       "existing-id",
+					"filename": "a.jpg",
+					"mediaMetadata": map[string]string{
+						"creationTime": "2025-02-16T20:32:22Z",
+					},
+				},
+			},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &GooglePhotosClient{
+		httpClient: server.Client(),
+		baseURL:    server.URL,
+		pacer:      NewPacer(server.Client()),
+	}
+
+	result, err := ProcessFolder(client, folder, make(map[string]string), nil, false, false, true, bufio.NewReader(os.Stdin), nil, nil, 4, false, context.Background(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Skipped != 1 {
+		t.Errorf("expected 1 auto-skipped duplicate, got %d", result.Skipped)
+	}
+	if uploadsCalled != 0 {
+		t.Errorf("expected the confirmed duplicate to never reach /uploads, got %d calls", uploadsCalled)
 	}
 }